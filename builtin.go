@@ -0,0 +1,181 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// builtinType describes a type supported by the package out of the box.
+type builtinType struct {
+	// name is the human-readable name of the type shown in the usage message.
+	name string
+
+	// parse decodes the value, the result must be of the type.
+	parse func(value string, opts fieldOptions) (interface{}, error)
+
+	// format formats the value, nil uses the default formatting.
+	format func(v reflect.Value, opts fieldOptions) string
+}
+
+// builtinTypes are the common infrastructure types supported by the package. The types
+// registered with RegisterType or WithType take precedence.
+var builtinTypes = map[reflect.Type]builtinType{
+	reflect.TypeOf(url.URL{}): {
+		name: "url",
+		parse: func(value string, _ fieldOptions) (interface{}, error) {
+			u, err := url.Parse(value)
+			if err != nil {
+				return nil, err
+			}
+			return *u, nil
+		},
+		format: func(v reflect.Value, _ fieldOptions) string {
+			u := v.Interface().(url.URL)
+			return u.String()
+		},
+	},
+	reflect.TypeOf(net.IP{}): {
+		name: "ip",
+		parse: func(value string, _ fieldOptions) (interface{}, error) {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, errors.New("invalid IP address: " + value)
+			}
+			return ip, nil
+		},
+		format: func(v reflect.Value, _ fieldOptions) string {
+			if v.Len() == 0 {
+				return ""
+			}
+			return v.Interface().(net.IP).String()
+		},
+	},
+	reflect.TypeOf(net.IPNet{}): {
+		name: "cidr",
+		parse: func(value string, _ fieldOptions) (interface{}, error) {
+			_, n, err := net.ParseCIDR(value)
+			if err != nil {
+				return nil, err
+			}
+			return *n, nil
+		},
+		format: func(v reflect.Value, _ fieldOptions) string {
+			n := v.Interface().(net.IPNet)
+			if n.IP == nil {
+				return ""
+			}
+			return n.String()
+		},
+	},
+	reflect.TypeOf(netip.Addr{}): {
+		name: "addr",
+		parse: func(value string, _ fieldOptions) (interface{}, error) {
+			return netip.ParseAddr(value)
+		},
+		format: func(v reflect.Value, _ fieldOptions) string {
+			a := v.Interface().(netip.Addr)
+			if !a.IsValid() {
+				return ""
+			}
+			return a.String()
+		},
+	},
+	reflect.TypeOf(netip.Prefix{}): {
+		name: "cidr",
+		parse: func(value string, _ fieldOptions) (interface{}, error) {
+			return netip.ParsePrefix(value)
+		},
+		format: func(v reflect.Value, _ fieldOptions) string {
+			p := v.Interface().(netip.Prefix)
+			if !p.IsValid() {
+				return ""
+			}
+			return p.String()
+		},
+	},
+	reflect.TypeOf(time.Time{}): {
+		name: "time",
+		parse: func(value string, opts fieldOptions) (interface{}, error) {
+			return time.Parse(opts.timeLayout(), value)
+		},
+		format: func(v reflect.Value, opts fieldOptions) string {
+			t := v.Interface().(time.Time)
+			if t.IsZero() {
+				return ""
+			}
+			if opts.layout != "" {
+				return t.Format(opts.layout)
+			}
+			return t.Format(time.RFC3339Nano)
+		},
+	},
+	reflect.TypeOf((*time.Location)(nil)): {
+		name: "location",
+		parse: func(value string, _ fieldOptions) (interface{}, error) {
+			return time.LoadLocation(value)
+		},
+	},
+	reflect.TypeOf(slog.Level(0)): {
+		name: "level",
+		parse: func(value string, _ fieldOptions) (interface{}, error) {
+			var l slog.Level
+			err := l.UnmarshalText([]byte(value))
+			return l, err
+		},
+	},
+	reflect.TypeOf(os.FileMode(0)): {
+		name: "filemode",
+		parse: func(value string, _ fieldOptions) (interface{}, error) {
+			m, err := strconv.ParseUint(value, 8, 32)
+			if err != nil {
+				return nil, errors.New("invalid file mode, expected octal number like 0644: " + value)
+			}
+			return os.FileMode(m), nil
+		},
+		format: func(v reflect.Value, _ fieldOptions) string {
+			return fmt.Sprintf("%#o", v.Uint())
+		},
+	},
+	reflect.TypeOf((*regexp.Regexp)(nil)): {
+		name: "regexp",
+		parse: func(value string, _ fieldOptions) (interface{}, error) {
+			return regexp.Compile(value)
+		},
+	},
+}
+
+// processBuiltin decodes the value if the type of the field is a built-in type. It reports
+// whether the type is a built-in type.
+func processBuiltin(value string, field reflect.Value, opts fieldOptions) (bool, error) {
+	b, ok := builtinTypes[field.Type()]
+	if !ok {
+		return false, nil
+	}
+
+	v, err := b.parse(value, opts)
+	if err != nil {
+		return true, err
+	}
+
+	field.Set(reflect.ValueOf(v))
+	return true, nil
+}
+
+// formatBuiltin formats the value if the type of the value is a built-in type with a formatter.
+func formatBuiltin(v reflect.Value, opts fieldOptions) (string, bool) {
+	b, ok := builtinTypes[v.Type()]
+	if !ok || b.format == nil {
+		return "", false
+	}
+
+	return b.format(v, opts), true
+}
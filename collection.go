@@ -0,0 +1,373 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// isStructCollection reports whether the type is a slice or a map of structs, or of pointers to
+// structs, which are not decoded as a whole. The fields of their elements are addressed with
+// indexed environment variables and flags.
+func isStructCollection(local typeRegistry, t reflect.Type) bool {
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Map {
+		return false
+	}
+
+	e := t.Elem()
+	if e.Kind() == reflect.Ptr {
+		e = e.Elem()
+	}
+
+	return e.Kind() == reflect.Struct && !isLeaf(local, e)
+}
+
+// elementFields returns the fields of a zero element of the collection type, they are used to
+// match the indexed environment variables and flags.
+func (l *Loader) elementFields(t reflect.Type) ([]Field, error) {
+	e := t.Elem()
+	if e.Kind() == reflect.Ptr {
+		e = e.Elem()
+	}
+
+	return l.extractFields(nil, reflect.New(e).Interface())
+}
+
+// expandFields adds the fields of the elements of the slices and maps of structs after the
+// collection fields. The elements are addressed with indexed environment variables and flags,
+// for example UPSTREAMS_0_HOST and --upstreams.0.host for []Upstream, or BACKENDS_PRIMARY_URL
+// and --backends.primary.url for map[string]Backend.
+//
+// The existing elements, for example the ones decoded from a file, are kept and the sources
+// override their fields. An index beyond the existing elements appends a new element in the
+// order of the indices, so sparse indices like 0 and 5 give two elements. The keys of maps
+// given with environment variables are lowercased.
+//
+// The map elements and the new pointer elements are processed in temporary values, the returned
+// functions store them into the collections once the fields are processed. The environment and
+// the flag sources can be nil, then only the existing elements are expanded.
+func (l *Loader) expandFields(fields []Field, e *env, fl *flag) ([]Field, []func(), error) {
+	var (
+		expanded []Field
+		commits  []func()
+	)
+
+	for _, f := range fields {
+		expanded = append(expanded, f)
+		if f.Args || !isStructCollection(l.types, f.FieldValue.Type()) {
+			continue
+		}
+
+		elemFields, err := l.elementFields(f.FieldValue.Type())
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing elements of field %s: %w", f.Name, err)
+		}
+
+		keys := l.collectionKeys(f, elemFields, e, fl)
+
+		var (
+			elems  []Field
+			commit []func()
+		)
+		if f.FieldValue.Kind() == reflect.Slice {
+			elems, commit, err = l.expandSlice(f, keys)
+		} else {
+			elems, commit, err = l.expandMap(f, keys, fl)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// the elements can have collections of their own
+		elems, nested, err := l.expandFields(elems, e, fl)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		expanded = append(expanded, elems...)
+		commits = append(commits, nested...)
+		commits = append(commits, commit...)
+	}
+
+	return expanded, commits, nil
+}
+
+// expandSlice grows the slice for the indices beyond its length and returns the fields of
+// all its elements.
+func (l *Loader) expandSlice(f Field, keys []string) ([]Field, []func(), error) {
+	v := f.FieldValue
+	n := v.Len()
+
+	var extra []int
+	for _, k := range keys {
+		// the index must be canonical, otherwise 01 and 1 would name two elements
+		i, err := strconv.Atoi(k)
+		if err != nil || strconv.Itoa(i) != k {
+			return nil, nil, fmt.Errorf("invalid index %q for field %s", k, f.Name)
+		}
+		if i >= n {
+			extra = append(extra, i)
+		}
+	}
+	sort.Ints(extra)
+
+	labels := make([]string, 0, n+len(extra))
+	for i := 0; i < n; i++ {
+		labels = append(labels, strconv.Itoa(i))
+	}
+	for _, i := range extra {
+		labels = append(labels, strconv.Itoa(i))
+	}
+
+	if len(extra) > 0 {
+		s := reflect.MakeSlice(v.Type(), len(labels), len(labels))
+		reflect.Copy(s, v)
+		v.Set(s)
+	}
+
+	var (
+		fields  []Field
+		commits []func()
+	)
+	for i, label := range labels {
+		elemFields, commit, err := l.elementOf(f, v.Index(i), label)
+		if err != nil {
+			return nil, nil, err
+		}
+		fields = append(fields, elemFields...)
+		commits = append(commits, commit...)
+	}
+
+	return fields, commits, nil
+}
+
+// expandMap returns the fields of the existing elements of the map and of the elements named
+// by the keys. The keys are matched case-insensitively with the existing keys, the flags of the
+// flag source naming an element with another case are renamed after the element.
+func (l *Loader) expandMap(f Field, keys []string, fl *flag) ([]Field, []func(), error) {
+	opts := l.options(f)
+	m := f.FieldValue
+	t := m.Type()
+
+	type entry struct {
+		key   reflect.Value
+		label string
+	}
+
+	var entries []entry
+	for _, k := range m.MapKeys() {
+		entries = append(entries, entry{key: k, label: valueToString(k, opts)})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].label < entries[j].label
+	})
+
+	for _, k := range keys {
+		found := false
+		for _, e := range entries {
+			if strings.EqualFold(e.label, k) {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		key := reflect.New(t.Key()).Elem()
+		if err := processField(k, key, opts); err != nil {
+			return nil, nil, fmt.Errorf("invalid key %q for field %s: %w", k, f.Name, err)
+		}
+		entries = append(entries, entry{key: key, label: k})
+	}
+
+	if len(entries) > 0 && m.IsNil() {
+		m.Set(reflect.MakeMap(t))
+	}
+
+	for _, e := range entries {
+		foldFlagKey(fl, f.Flag, e.label)
+	}
+
+	var (
+		fields  []Field
+		commits []func()
+	)
+	for _, e := range entries {
+		elem := reflect.New(t.Elem()).Elem()
+		if v := m.MapIndex(e.key); v.IsValid() {
+			elem.Set(v)
+		}
+
+		elemFields, commit, err := l.elementOf(f, elem, e.label)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		key := e.key
+		commits = append(commits, commit...)
+		commits = append(commits, func() {
+			m.SetMapIndex(key, elem)
+		})
+		fields = append(fields, elemFields...)
+	}
+
+	return fields, commits, nil
+}
+
+// foldFlagKey renames the flags of the map element given with another case of the key, for example
+// --backends.primary.url for the key Primary, to the label of the element.
+func foldFlagKey(fl *flag, prefix, label string) {
+	if fl == nil {
+		return
+	}
+
+	for i, v := range fl.values {
+		rest, ok := strings.CutPrefix(v.Name, prefix+".")
+		if !ok {
+			continue
+		}
+		key, sub, ok := strings.Cut(rest, ".")
+		if ok && key != label && strings.EqualFold(key, label) {
+			fl.values[i].Name = prefix + "." + label + "." + sub
+		}
+	}
+}
+
+// elementOf returns the fields of the element of the collection field named with the label.
+// A nil pointer element is replaced with a new struct when the returned functions are called.
+func (l *Loader) elementOf(f Field, elem reflect.Value, label string) ([]Field, []func(), error) {
+	var (
+		target  reflect.Value
+		commits []func()
+	)
+	switch {
+	case elem.Kind() != reflect.Ptr:
+		target = elem.Addr()
+	case elem.IsNil():
+		target = reflect.New(elem.Type().Elem())
+		commits = append(commits, func() {
+			elem.Set(target)
+		})
+	default:
+		target = elem
+	}
+
+	fields, err := l.extractFields(nil, target.Interface())
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing element %s of field %s: %w", label, f.Name, err)
+	}
+
+	return prefixFields(f, fields, label), commits, nil
+}
+
+// prefixFields prefixes the names of the element fields with the name of the collection field
+// and the label of the element, for example UPSTREAMS_0_HOST and upstreams.0.host.
+func prefixFields(f Field, fields []Field, label string) []Field {
+	for i := range fields {
+		fields[i].Name = f.Name + "_" + label + "_" + fields[i].Name
+		fields[i].EnvVar = f.EnvVar + "_" + strings.ToUpper(label) + "_" + fields[i].EnvVar
+		fields[i].Flag = f.Flag + "." + label + "." + fields[i].Flag
+		fields[i].ShortFlag = 0
+	}
+	inSection(fields, f.section)
+	markStatic(fields, f.Static)
+	markHidden(fields, f.Hidden)
+	if f.Group != "" {
+		inGroup(fields, f.Group)
+	}
+
+	return fields
+}
+
+// collectionKeys returns the indices or the keys of the elements of the collection field
+// addressed by the environment variables and the flags.
+func (l *Loader) collectionKeys(f Field, elemFields []Field, e *env, fl *flag) []string {
+	isSlice := f.FieldValue.Kind() == reflect.Slice
+
+	var keys []string
+	seen := make(map[string]bool)
+	add := func(k string) {
+		if isSlice && !isIndex(k) || seen[k] {
+			return
+		}
+		seen[k] = true
+		keys = append(keys, k)
+	}
+
+	if e != nil {
+		names := make([]string, 0, len(e.m))
+		for name := range e.m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			rest, ok := strings.CutPrefix(name, f.EnvVar+"_")
+			if !ok {
+				continue
+			}
+
+			// the key is the shortest prefix followed by the name of an element field,
+			// so the keys can contain underscores too
+			for i := 1; i < len(rest); i++ {
+				if rest[i] == '_' && l.matchesElementEnv(rest[i+1:], elemFields) {
+					add(strings.ToLower(rest[:i]))
+					break
+				}
+			}
+		}
+	}
+
+	if fl != nil {
+		spec := l.newFlagSpec(elemFields)
+		for _, v := range fl.values {
+			rest, ok := strings.CutPrefix(v.Name, f.Flag+".")
+			if !ok {
+				continue
+			}
+
+			key, sub, ok := strings.Cut(rest, ".")
+			if !ok || key == "" {
+				continue
+			}
+			if _, known := spec.lookup(sub); known {
+				add(key)
+			}
+		}
+	}
+
+	return keys
+}
+
+// matchesElementEnv reports whether the name is the environment variable of an element field,
+// or of an element of a nested collection.
+func (l *Loader) matchesElementEnv(name string, elemFields []Field) bool {
+	for _, f := range elemFields {
+		if name == f.EnvVar {
+			return true
+		}
+		if isStructCollection(l.types, f.FieldValue.Type()) && strings.HasPrefix(name, f.EnvVar+"_") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isIndex reports whether the string is a slice index.
+func isIndex(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,240 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrNoCommand is returned when the command line doesn't name a command.
+	ErrNoCommand = errors.New("command not specified")
+	// ErrUnknownCommand is returned when the command line names a command that doesn't exist.
+	ErrUnknownCommand = errors.New("unknown command")
+)
+
+// Command is a subcommand of the application, for example "serve" or "migrate".
+type Command struct {
+	// Name is the name of the command on the command line.
+	Name string
+
+	// Usage is the short description of the command shown in the usage message.
+	Usage string
+
+	// Config is the pointer to the configuration struct of the command. It can be nil
+	// when the command has no options of its own.
+	Config interface{}
+
+	// Run is executed after the root and the command configuration are loaded.
+	Run func(ctx context.Context) error
+}
+
+// Commands dispatches the command line to the subcommands. The root configuration struct is shared
+// by all the commands, its flags can be given before or after the name of the command:
+//
+//	app --verbose serve --port 8080
+//	app serve --port 8080 --verbose
+type Commands struct {
+	// Root is the pointer to the configuration struct shared by all the commands. It can be nil.
+	Root interface{}
+
+	// Commands are the subcommands of the application.
+	Commands []Command
+}
+
+// Execute loads the root and the command configuration with a Loader configured with the given
+// options, then runs the command named on the command line. When help is requested at any level,
+// the usage message of that level is written to the output of the Loader, see WithOutput, and ErrHelp
+// is returned. The completion script requested with --completion=<shell> covers all the commands.
+func (c *Commands) Execute(ctx context.Context, opts ...Option) error {
+	l := NewLoader(opts...)
+
+	rootFields, err := c.fields(l, c.Root)
+	if err != nil {
+		return err
+	}
+
+	// the global flags before the name of the command
+	global, err := l.parseFlags(l.args, rootFields, true)
+	if err != nil {
+		return c.builtin(l, err, "", opts)
+	}
+
+	if len(global.positional) == 0 {
+		return ErrNoCommand
+	}
+
+	name := global.positional[0]
+	cmd, err := c.command(name)
+	if err != nil {
+		return err
+	}
+
+	// process the struct with the given parsers, the root and the command read the same
+	// files, so the values left to the Loader are merged
+	file := newFileSource()
+	for _, cfg := range []interface{}{c.Root, cmd.Config} {
+		if cfg == nil {
+			continue
+		}
+		src, err := l.parse(cfg)
+		if err != nil {
+			return err
+		}
+		for k, v := range src.m {
+			file.m[k] = v
+		}
+	}
+
+	// the fields are extracted again after the parsers, which may have allocated the optional
+	// sections, so the flags and environment variables are applied to the parsed values
+	rootFields, err = c.fields(l, c.Root)
+	if err != nil {
+		return err
+	}
+
+	cmdFields, err := c.fields(l, cmd.Config)
+	if err != nil {
+		return err
+	}
+
+	// the command line without the name of the command, so the global flags
+	// can be given after the name of the command too
+	pos := len(l.args) - len(global.positional)
+	args := make([]string, 0, len(l.args)-1)
+	args = append(args, l.args[:pos]...)
+	args = append(args, l.args[pos+1:]...)
+
+	fields := append(rootFields, cmdFields...)
+	if err := l.parseWithDefaultSource(args, fields, file); err != nil {
+		return c.builtin(l, err, cmd.Name, opts)
+	}
+
+	if cmd.Run == nil {
+		return fmt.Errorf("command %s has no run function", cmd.Name)
+	}
+
+	return cmd.Run(ctx)
+}
+
+// UsageMessage generates the usage message of the command with the given name. The empty name
+// generates the usage message of the application listing all the commands. The options of the
+// Loader customize the message like with the package level UsageMessage.
+func (c *Commands) UsageMessage(name string, opts ...Option) (string, error) {
+	l := NewLoader(opts...)
+
+	rootFields, err := c.fields(l, c.Root)
+	if err != nil {
+		return "", err
+	}
+
+	if name == "" {
+		return l.usage(usageData{
+			Description: defaultDescription,
+			Field:       l.optionFields(rootFields),
+			Commands:    c.Commands,
+		})
+	}
+
+	cmd, err := c.command(name)
+	if err != nil {
+		return "", err
+	}
+
+	cmdFields, err := c.fields(l, cmd.Config)
+	if err != nil {
+		return "", err
+	}
+
+	return l.usage(usageData{
+		Command:     cmd.Name,
+		Description: cmd.Usage,
+		Field:       l.optionFields(cmdFields),
+		Global:      l.optionFields(rootFields),
+	})
+}
+
+// command returns the command with the given name.
+func (c *Commands) command(name string) (Command, error) {
+	names := make([]string, 0, len(c.Commands))
+	for _, cmd := range c.Commands {
+		if cmd.Name == name {
+			return cmd, nil
+		}
+		names = append(names, cmd.Name)
+	}
+
+	err := fmt.Errorf("%w: %s", ErrUnknownCommand, name)
+	if s := suggest(name, names); len(s) > 0 {
+		err = fmt.Errorf("%w (did you mean %s?)", err, strings.Join(s, " or "))
+	}
+
+	return Command{}, err
+}
+
+// fields returns the fields of the configuration struct, nil struct has no fields.
+func (c *Commands) fields(l *Loader, cfg interface{}) ([]Field, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	return l.extractFields(nil, cfg)
+}
+
+// builtin handles the errors of the built-in flags. On ErrHelp the usage message of the command
+// is written to the output of the Loader, on a completion request the completion script of all the
+// commands is written and ErrCompletion is returned, on a sample request the sample configuration file
+// of the command is written and ErrSampleConfig is returned. Other errors are returned as is.
+func (c *Commands) builtin(l *Loader, err error, name string, opts []Option) error {
+	if errors.Is(err, ErrCompletion) {
+		fields, fErr := c.fields(l, c.Root)
+		if fErr != nil {
+			return fErr
+		}
+		for _, cmd := range c.Commands {
+			cmdFields, fErr := c.fields(l, cmd.Config)
+			if fErr != nil {
+				return fErr
+			}
+			fields = append(fields, cmdFields...)
+		}
+
+		return completionScript(err, fields, c.Commands, l.write)
+	}
+
+	if errors.Is(err, ErrSampleConfig) {
+		fields, fErr := c.fields(l, c.Root)
+		if fErr != nil {
+			return fErr
+		}
+		if name != "" {
+			cmd, cErr := c.command(name)
+			if cErr != nil {
+				return cErr
+			}
+			cmdFields, fErr := c.fields(l, cmd.Config)
+			if fErr != nil {
+				return fErr
+			}
+			fields = append(fields, cmdFields...)
+		}
+
+		return l.sampleConfig(err, fields)
+	}
+
+	if !errors.Is(err, ErrHelp) {
+		return err
+	}
+
+	usage, uErr := c.UsageMessage(name, opts...)
+	if uErr != nil {
+		return uErr
+	}
+
+	if wErr := l.write(usage); wErr != nil {
+		return wErr
+	}
+
+	return err
+}
@@ -0,0 +1,293 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrCompletion is returned when the completion script is requested with the hidden --completion flag.
+// The script is written to the output of the Loader before the error is returned.
+var ErrCompletion = errors.New("completion requested")
+
+// completionRequest is returned by the flag parser when --completion=<shell> is set.
+type completionRequest struct {
+	shell string
+}
+
+func (c *completionRequest) Error() string {
+	return ErrCompletion.Error() + ": " + c.shell
+}
+
+func (c *completionRequest) Is(target error) bool {
+	return target == ErrCompletion
+}
+
+// completionFlag is the name of the hidden built-in flag printing the completion script.
+const completionFlag = "completion"
+
+// completionOption is a flag offered by the completion script.
+type completionOption struct {
+	long     string
+	short    string
+	usage    string
+	isBool   bool
+	values   []string
+	complete string
+}
+
+// Completion generates the completion script of the configuration struct for the given shell:
+// bash, zsh or fish. The values of the fields with the oneof tag are completed from the list and
+// the fields with the complete tag are completed with file or directory names.
+func Completion(cfg interface{}, shell string) (string, error) {
+	fields, err := NewLoader().extractFields(nil, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	return completion(programName(), fields, nil, shell)
+}
+
+// completion generates the completion script for the fields and the commands.
+func completion(name string, fields []Field, commands []Command, shell string) (string, error) {
+	opts := completionOptions(fields)
+
+	switch shell {
+	case "bash":
+		return bashCompletion(name, opts, commands), nil
+	case "zsh":
+		return zshCompletion(name, opts, commands), nil
+	case "fish":
+		return fishCompletion(name, opts, commands), nil
+	}
+
+	return "", errors.New("unsupported shell: " + shell)
+}
+
+// completionOptions returns the flags of the fields followed by the built-in flags.
+func completionOptions(fields []Field) []completionOption {
+	var opts []completionOption
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		if f.Args || f.Hidden || seen[f.Flag] {
+			continue
+		}
+		seen[f.Flag] = true
+
+		opt := completionOption{
+			long:     f.Flag,
+			usage:    f.Usage,
+			isBool:   isBoolField(f.FieldValue),
+			values:   f.OneOf,
+			complete: f.Complete,
+		}
+		if f.ShortFlag != 0 {
+			opt.short = string(f.ShortFlag)
+		}
+		opts = append(opts, opt)
+
+		if opt.isBool {
+			opts = append(opts, completionOption{long: "no-" + f.Flag, usage: "disable " + f.Flag, isBool: true})
+		}
+	}
+
+	return append(opts,
+		completionOption{long: "help", short: "h", usage: "show this help message", isBool: true},
+		completionOption{long: "version", short: "v", usage: "show version", isBool: true},
+	)
+}
+
+// bashCompletion generates the bash completion script.
+func bashCompletion(name string, opts []completionOption, commands []Command) string {
+	fn := "_" + identifier(name) + "_completions"
+
+	var words []string
+	var sb strings.Builder
+	sb.WriteString("# bash completion for " + name + "\n")
+	sb.WriteString(fn + "() {\n")
+	sb.WriteString("    local cur prev\n")
+	sb.WriteString("    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	sb.WriteString("    prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n\n")
+	sb.WriteString("    case \"$prev\" in\n")
+	for _, o := range opts {
+		words = append(words, "--"+o.long)
+		if o.short != "" {
+			words = append(words, "-"+o.short)
+		}
+		if o.isBool {
+			continue
+		}
+
+		pattern := "--" + o.long
+		if o.short != "" {
+			pattern += "|-" + o.short
+		}
+
+		var reply string
+		switch {
+		case len(o.values) > 0:
+			reply = "$(compgen -W \"" + strings.Join(o.values, " ") + "\" -- \"$cur\")"
+		case o.complete == "file":
+			reply = "$(compgen -f -- \"$cur\")"
+		case o.complete == "dir":
+			reply = "$(compgen -d -- \"$cur\")"
+		default:
+			// the value can't be completed, don't offer the flags
+			reply = ""
+		}
+		sb.WriteString("        " + pattern + ")\n")
+		sb.WriteString("            COMPREPLY=(" + reply + ")\n")
+		sb.WriteString("            return\n")
+		sb.WriteString("            ;;\n")
+	}
+	sb.WriteString("    esac\n\n")
+
+	for _, c := range commands {
+		words = append(words, c.Name)
+	}
+	sb.WriteString("    COMPREPLY=($(compgen -W \"" + strings.Join(words, " ") + "\" -- \"$cur\"))\n")
+	sb.WriteString("}\n\n")
+	sb.WriteString("complete -F " + fn + " " + name + "\n")
+
+	return sb.String()
+}
+
+// zshCompletion generates the zsh completion script.
+func zshCompletion(name string, opts []completionOption, commands []Command) string {
+	fn := "_" + identifier(name)
+
+	var sb strings.Builder
+	sb.WriteString("#compdef " + name + "\n\n")
+	sb.WriteString(fn + "() {\n")
+	sb.WriteString("    _arguments \\\n")
+	for _, o := range opts {
+		var desc string
+		if o.usage != "" {
+			desc = "[" + zshEscape(o.usage) + "]"
+		}
+
+		spec := "'--" + o.long + desc
+		if o.short != "" {
+			spec = "'(-" + o.short + " --" + o.long + ")'{-" + o.short + ",--" + o.long + "}'" + desc
+		}
+
+		if !o.isBool {
+			switch {
+			case len(o.values) > 0:
+				spec += ":" + o.long + ":(" + strings.Join(o.values, " ") + ")"
+			case o.complete == "file":
+				spec += ":" + o.long + ":_files"
+			case o.complete == "dir":
+				spec += ":" + o.long + ":_files -/"
+			default:
+				spec += ":" + o.long + ": "
+			}
+		}
+		sb.WriteString("        " + spec + "' \\\n")
+	}
+
+	if len(commands) > 0 {
+		var names []string
+		for _, c := range commands {
+			names = append(names, zshEscape(c.Name)+"\\:"+strings.ReplaceAll(zshEscape(c.Usage), " ", "\\ "))
+		}
+		sb.WriteString("        '1:command:((" + strings.Join(names, " ") + "))' \\\n")
+	}
+	sb.WriteString("        '*::arg:_files'\n")
+	sb.WriteString("}\n\n")
+	sb.WriteString("compdef " + fn + " " + name + "\n")
+
+	return sb.String()
+}
+
+// fishCompletion generates the fish completion script.
+func fishCompletion(name string, opts []completionOption, commands []Command) string {
+	var sb strings.Builder
+	sb.WriteString("# fish completion for " + name + "\n")
+	for _, o := range opts {
+		line := "complete -c " + name
+		if o.short != "" {
+			line += " -s " + o.short
+		}
+		line += " -l " + o.long
+		if o.usage != "" {
+			line += " -d " + fishQuote(o.usage)
+		}
+
+		if !o.isBool {
+			switch {
+			case len(o.values) > 0:
+				line += " -x -a " + fishQuote(strings.Join(o.values, " "))
+			case o.complete == "file":
+				line += " -r -F"
+			case o.complete == "dir":
+				line += " -x -a '(__fish_complete_directories)'"
+			default:
+				line += " -x"
+			}
+		}
+		sb.WriteString(line + "\n")
+	}
+
+	for _, c := range commands {
+		line := "complete -c " + name + " -n __fish_use_subcommand -f -a " + fishQuote(c.Name)
+		if c.Usage != "" {
+			line += " -d " + fishQuote(c.Usage)
+		}
+		sb.WriteString(line + "\n")
+	}
+
+	return sb.String()
+}
+
+// programName returns the base name of the program used in the completion scripts.
+func programName() string {
+	if len(os.Args) == 0 {
+		return "app"
+	}
+
+	return filepath.Base(os.Args[0])
+}
+
+// identifier converts the name to a valid shell function name.
+func identifier(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// zshEscape escapes the text for the zsh _arguments spec given in single quotes.
+func zshEscape(s string) string {
+	r := strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`)
+	return r.Replace(s)
+}
+
+// fishQuote quotes the text with single quotes for fish.
+func fishQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "'", `\'`)
+	return "'" + r.Replace(s) + "'"
+}
+
+// completionScript writes the completion script for the shell of the request when the error
+// is a completion request, and returns ErrCompletion. Other errors are returned as is.
+func completionScript(err error, fields []Field, commands []Command, write func(string) error) error {
+	var req *completionRequest
+	if !errors.As(err, &req) {
+		return err
+	}
+
+	script, cErr := completion(programName(), fields, commands, req.shell)
+	if cErr != nil {
+		return cErr
+	}
+
+	if wErr := write(script); wErr != nil {
+		return wErr
+	}
+
+	return ErrCompletion
+}
@@ -2,7 +2,8 @@ package config
 
 import (
 	"errors"
-	"os"
+	"reflect"
+	"strings"
 )
 
 // Decoder is the interface that wraps the Decode method. Can be used to implement custom decoders.
@@ -16,11 +17,33 @@ type Parser interface {
 	Parse(cfg interface{}) error
 }
 
+// KeyParser is implemented by the parsers which can report the keys present in the parsed document,
+// for example yaml.File. Keys returns the path of every key, like [http port] for the port key nested
+// under http. The fields set by these keys are validated even when they hold the zero value, so
+// "port: 0" is checked against the min rule while an absent key is not.
+type KeyParser interface {
+	Parser
+	Keys() ([][]string, error)
+}
+
+// TextParser is implemented by the parsers which can leave the values of some keys to the Loader, for
+// example yaml.File. ParseText parses the document into cfg like Parse, except the scalar values of the
+// keys at the given paths, which are left out and returned as text keyed by the index of the path. The
+// Loader decodes them like the environment variables, so the fields of the types the file format has no
+// representation for, like url.URL or os.FileMode, are read from their text form.
+type TextParser interface {
+	Parser
+	ParseText(cfg interface{}, paths [][]string) (map[int]string, error)
+}
+
 // source is the interface that wraps the Source method which is used to load the configuration
 // from environment variables and command line flags.
 // Source method accepts Field struct
 type source interface {
 	Source(f Field) (string, bool)
+
+	// origin describes where the value of the field comes from, it is used in the errors.
+	origin(f Field) string
 }
 
 // MutatorFunc is a function that mutates a value of the key before it is set to the field.
@@ -29,44 +52,55 @@ type MutatorFunc func(key, value string) (string, error)
 // Process processes the struct with environment variables and command line flags source. It also
 // accepts mutator function to mutate the value before it is set to the field.
 func Process(cfg interface{}, mutator ...MutatorFunc) error {
-	var args []string
-	if len(os.Args) > 1 {
-		args = os.Args[1:]
-	}
-
-	return parseWithDefaultSource(args, cfg, mutator...)
+	return NewLoader(WithMutators(mutator...)).Load(cfg)
 }
 
 // ProcessWithParser processes the struct with the given parsers. After processing with the parsers
 // it will process the struct with environment variables and command line flags source.
 // It also accepts mutator function to mutate the value before it is set to the field.
 func ProcessWithParser(cfg interface{}, parsers []Parser, mutator ...MutatorFunc) error {
-	var args []string
-	if len(os.Args) > 1 {
-		args = os.Args[1:]
+	return NewLoader(WithParsers(parsers...), WithMutators(mutator...)).Load(cfg)
+}
+
+// parse processes the struct with the parsers of the Loader. The parsers implementing TextParser leave
+// the values of the fields decoded from text to the Loader, they are returned as the file source.
+func (l *Loader) parse(cfg interface{}) (*file, error) {
+	fields, err := l.extractFields(nil, cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	// process the struct with the given parsers
-	if err := processWithParser(cfg, parsers...); err != nil {
-		return err
+	var paths [][]string
+	for _, f := range fields {
+		if len(f.path) > 0 && l.isFileText(f.FieldValue.Type()) {
+			paths = append(paths, f.path)
+		}
 	}
 
-	return parseWithDefaultSource(args, cfg, mutator...)
-}
+	src := newFileSource()
+	for _, p := range l.parsers {
+		tp, ok := p.(TextParser)
+		if !ok || len(paths) == 0 {
+			if err := p.Parse(cfg); err != nil {
+				return nil, err
+			}
+			continue
+		}
 
-// processWithParser processes the struct with the given parsers.
-func processWithParser(cfg interface{}, parsers ...Parser) error {
-	for _, p := range parsers {
-		if err := p.Parse(cfg); err != nil {
-			return err
+		texts, err := tp.ParseText(cfg, paths)
+		if err != nil {
+			return nil, err
+		}
+		for i, text := range texts {
+			src.set(paths[i], text)
 		}
 	}
 
-	return nil
+	return src, nil
 }
 
-// processWithSource processes the Field with the given source and mutator.
-func processWithSource(f Field, source []source, mutator ...MutatorFunc) error {
+// processWithSource processes the Field with the given source and the mutators of the Loader.
+func (l *Loader) processWithSource(f Field, source []source) error {
 	for _, src := range source {
 		if src == nil {
 			continue
@@ -79,9 +113,10 @@ func processWithSource(f Field, source []source, mutator ...MutatorFunc) error {
 		}
 
 		// if mutator is provided then execute the mutator
-		// before setting the value to the field
-		if len(mutator) > 0 {
-			for _, m := range mutator {
+		// before setting the value to the field, the values of
+		// the configuration files are not mutated
+		if _, ok := src.(*file); !ok && len(l.mutators) > 0 {
+			for _, m := range l.mutators {
 				if m == nil {
 					continue
 				}
@@ -94,8 +129,8 @@ func processWithSource(f Field, source []source, mutator ...MutatorFunc) error {
 			}
 		}
 
-		if err := processField(val, f.FieldValue); err != nil {
-			return errors.New("error processing field: " + f.Name + ", error: " + err.Error())
+		if err := processField(val, f.FieldValue, l.options(f)); err != nil {
+			return errors.New("error processing field: " + f.Name + " from " + src.origin(f) + ", error: " + err.Error())
 		}
 
 	}
@@ -103,42 +138,161 @@ func processWithSource(f Field, source []source, mutator ...MutatorFunc) error {
 	return nil
 }
 
-// parseWithDefaultSource parses the struct with environment variables and command line flags source.
-// It also accepts mutator function to mutate the value before it is set to the field.
-func parseWithDefaultSource(args []string, cfg interface{}, mutator ...MutatorFunc) error {
-	flag, err := newFlagParser(args)
+// parseWithDefaultSource parses the fields with the values left by the parsers, environment variables
+// and command line flags source. The mutators of the Loader are executed before the value from the
+// environment variables and the flags is set to the field.
+func (l *Loader) parseWithDefaultSource(args []string, fields []Field, file *file) error {
+	flag, err := l.newFlagParser(args, fields)
 	if err != nil {
 		return err
 	}
 
-	sources := []source{newEnvSource(), flag}
+	flag.options = l.options
+
+	env := newEnvSource()
+	sources := []source{file, env, flag}
 
-	fields, err := extractFields(nil, cfg)
+	// set holds the flags of the fields set by a parser, a source or a default, they are validated
+	// even when they hold the zero value
+	set, err := l.parsedFields(fields)
 	if err != nil {
 		return err
 	}
 
+	// the collections of structs can be given as a whole with JSON literals, the indexed
+	// environment variables and flags override the fields of their elements
 	for _, f := range fields {
+		if isStructCollection(l.types, f.FieldValue.Type()) {
+			if _, err := l.processSources(f, sources); err != nil {
+				return err
+			}
+		}
+	}
+
+	// the fields of the elements of slices and maps of structs
+	fields, commits, err := l.expandFields(fields, env, flag)
+	if err != nil {
+		return err
+	}
+
+	if l.strict {
+		if err := checkUnknown(fields, flag, env, l.envPrefix); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range fields {
+		if isStructCollection(l.types, f.FieldValue.Type()) {
+			// the collection is processed before its elements are expanded
+			continue
+		}
+
+		if f.Args {
+			// collect the positional arguments into the field
+			if len(flag.Args()) > 0 {
+				f.FieldValue.Set(reflect.ValueOf(flag.Args()))
+				f.section.markSet()
+			}
+			continue
+		}
+
 		// set the default value to the field if any
 		// and make sure not to override the value if already set by Parser
 		if f.Default != "" && f.FieldValue.IsZero() {
-			if err := processField(f.Default, f.FieldValue); err != nil {
+			if err := processField(f.Default, f.FieldValue, l.options(f)); err != nil {
 				return errors.New("error processing default value: " + f.Name + ", error: " + err.Error())
 			}
+			set[f.Flag] = true
 		}
 
 		// process the field with the given sources
-		if err := processWithSource(f, sources, mutator...); err != nil {
+		found, err := l.processSources(f, sources)
+		if err != nil {
 			return err
 		}
+		if found {
+			set[f.Flag] = true
+		}
+	}
 
-		// after processing the field at this point all the fields should be set
-		// and if required field is not set then return error
-		if f.Required && f.FieldValue.IsZero() {
-			return errors.New("required field not set: " + f.Name)
+	// the sections first, they can be in the map elements stored by the commits
+	for _, s := range sections(fields) {
+		s.commit()
+	}
+	for _, commit := range commits {
+		commit()
+	}
+
+	// the fields of the optional sections which are not set are not checked
+	for _, f := range fields {
+		if !f.section.active() {
+			continue
+		}
+		if err := l.checkField(f, set[f.Flag]); err != nil {
+			return err
 		}
 	}
 
 	return nil
+}
+
+// processSources processes the field with the sources and reports whether any of them has a value
+// for the field. The optional section of the field is allocated when the field is set.
+func (l *Loader) processSources(f Field, sources []source) (bool, error) {
+	found := false
+	for _, src := range sources {
+		if _, ok := src.Source(f); ok {
+			found = true
+			break
+		}
+	}
+	if found {
+		f.section.markSet()
+	}
+
+	return found, l.processWithSource(f, sources)
+}
 
+// parsedFields returns the flags of the fields set by the parsers implementing KeyParser.
+func (l *Loader) parsedFields(fields []Field) (map[string]bool, error) {
+	set := make(map[string]bool)
+	for _, p := range l.parsers {
+		kp, ok := p.(KeyParser)
+		if !ok {
+			continue
+		}
+
+		keys, err := kp.Keys()
+		if err != nil {
+			return nil, err
+		}
+
+		paths := make(map[string]bool, len(keys))
+		for _, k := range keys {
+			paths[strings.Join(k, "\x00")] = true
+		}
+		for _, f := range fields {
+			if len(f.path) > 0 && paths[strings.Join(f.path, "\x00")] {
+				set[f.Flag] = true
+			}
+		}
+	}
+
+	return set, nil
+}
+
+// checkField validates the value of the processed field and makes sure the required field is set.
+// The zero value of a field which is not set is not validated.
+func (l *Loader) checkField(f Field, set bool) error {
+	if err := validateField(f, l.options(f), set); err != nil {
+		return err
+	}
+
+	// after processing the field at this point all the fields should be set
+	// and if required field is not set then return error
+	if f.Required && f.FieldValue.IsZero() {
+		return errors.New("required field not set: " + f.Name)
+	}
+
+	return nil
 }
@@ -1,10 +1,24 @@
 package config_test
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/farrukhny/config"
+	"github.com/farrukhny/config/yaml"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -198,3 +212,2394 @@ func TestProcess(t *testing.T) {
 		}
 	}
 }
+
+func TestStartupMessageMask(t *testing.T) {
+	type secrets struct {
+		Full     string        `env:"FULL" mask:"true"`
+		Last4    string        `env:"LAST4" mask:"last4"`
+		First2   string        `env:"FIRST2" mask:"first2"`
+		Hash     string        `env:"HASH" mask:"hash"`
+		Length   string        `env:"LENGTH" mask:"length"`
+		Pin      string        `env:"PIN" mask:"last4"`
+		DBPass   string        `env:"DB_PASSWORD"`
+		APIToken string        `env:"API_TOKEN" mask:"false"`
+		TokenTTL time.Duration `env:"TOKEN_TTL"`
+		MinLen   int           `env:"PASSWORD_MIN_LENGTH"`
+		Rotation string        `env:"SECRET_ROTATION_INTERVAL"`
+	}
+
+	cfg := secrets{
+		Full:     "supersecret",
+		Last4:    "supersecret",
+		First2:   "supersecret",
+		Hash:     "supersecret",
+		Length:   "secret",
+		Pin:      "1234",
+		DBPass:   "password",
+		APIToken: "visible",
+		TokenTTL: time.Hour,
+		MinLen:   12,
+		Rotation: "24h",
+	}
+
+	want := []string{
+		"--full: ********\n",
+		"--last4: *******cret\n",
+		"--first2: su*********\n",
+		"--hash: sha256:f75778f7425b\n",
+		"--length: ******\n",
+		"--pin: ********\n",
+		"--dbpass: ********\n",
+		"--apitoken: visible\n",
+		"--token-ttl: 1h0m0s\n",
+		"--min-len: 12\n",
+		"--rotation: 24h\n",
+	}
+
+	t.Logf("Given the need to test masking of the startup message")
+	{
+		os.Args = []string{"config.test"}
+		msg, err := config.StartupMessage(&cfg)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to build the startup message: %v", failed, err)
+		}
+		t.Logf("\t%s\tShould be able to build the startup message.", success)
+
+		for _, w := range want {
+			if !strings.Contains(msg, w) {
+				t.Fatalf("\t%s\tShould contain %q in:\n%s", failed, w, msg)
+			}
+		}
+		t.Logf("\t%s\tShould mask the values according to the mask mode.", success)
+
+		msg, err = config.StartupMessage(&cfg, config.WithMaskPatterns())
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to build the startup message without automatic masking: %v", failed, err)
+		}
+		if !strings.Contains(msg, "--dbpass: password\n") || !strings.Contains(msg, "--full: ********\n") {
+			t.Fatalf("\t%s\tShould mask only the fields with the mask tag:\n%s", failed, msg)
+		}
+		t.Logf("\t%s\tShould mask only the fields with the mask tag.", success)
+	}
+}
+
+func TestProcessFlags(t *testing.T) {
+	type cli struct {
+		All     bool              `flag:"all" shortFlag:"a"`
+		Brief   bool              `flag:"brief" shortFlag:"b"`
+		Port    int               `flag:"port" shortFlag:"p"`
+		Offset  int               `flag:"offset"`
+		Name    string            `flag:"name" shortFlag:"n"`
+		Files   []string          `args:"true"`
+		Verbose bool              `flag:"verbose"`
+		Tags    []string          `flag:"tag" shortFlag:"t"`
+		Labels  map[string]string `flag:"label"`
+		Queries []string          `flag:"query" delim:";"`
+		Ignore  bool              `flag:"ignore" shortFlag:"i"`
+	}
+
+	test := []struct {
+		name string
+		args []string
+		want cli
+	}{
+		{
+			name: "Bundled short flags",
+			args: []string{"cli.test", "-abp8080"},
+			want: cli{All: true, Brief: true, Port: 8080},
+		},
+		{
+			name: "Attached and separated short values",
+			args: []string{"cli.test", "-p", "9090", "-nfoo"},
+			want: cli{Port: 9090, Name: "foo"},
+		},
+		{
+			name: "Negative numbers",
+			args: []string{"cli.test", "--offset", "-5", "-p=-1", "-10"},
+			want: cli{Offset: -5, Port: -1, Files: []string{"-10"}},
+		},
+		{
+			name: "Bundled short flags spelling a float keyword",
+			args: []string{"cli.test", "-inf", "-2.5"},
+			want: cli{Ignore: true, Name: "f", Files: []string{"-2.5"}},
+		},
+		{
+			name: "Positional arguments and terminator",
+			args: []string{"cli.test", "a.txt", "--all", "b.txt", "--", "--brief", "-p"},
+			want: cli{All: true, Files: []string{"a.txt", "b.txt", "--brief", "-p"}},
+		},
+		{
+			name: "Booleans do not consume the next argument",
+			args: []string{"cli.test", "--verbose", "c.txt", "-port", "1"},
+			want: cli{Verbose: true, Port: 1, Files: []string{"c.txt"}},
+		},
+		{
+			name: "Repeated flags accumulate",
+			args: []string{"cli.test", "--tag", "a", "-tb", "--tag=c,d", "--label", "env=prod", "--label", "team:core", "--label", "docs=https://x.io", "--port", "1", "--port", "2"},
+			want: cli{Port: 2, Tags: []string{"a", "b", "c", "d"}, Labels: map[string]string{"env": "prod", "team": "core", "docs": "https://x.io"}},
+		},
+		{
+			name: "Per-field delimiter",
+			args: []string{"cli.test", "--query", "a=1,b=2", "--query", "c=3"},
+			want: cli{Queries: []string{"a=1,b=2", "c=3"}},
+		},
+	}
+
+	for _, tt := range test {
+		t.Logf("Given the need to test the flag parser with %s", tt.name)
+		{
+			os.Clearenv()
+
+			f := func(t *testing.T) {
+				os.Args = tt.args
+				var cfg cli
+				if err := config.Process(&cfg); err != nil {
+					t.Fatalf("\t%s\tShould be able to process the cli struct: %v", failed, err)
+				}
+				t.Logf("\t%s\tShould be able to process the cli struct.", success)
+
+				if diff := cmp.Diff(tt.want, cfg); diff != "" {
+					t.Fatalf("\t%s\tShould get the expected config: %s", failed, diff)
+				}
+				t.Logf("\t%s\tShould get the expected config.", success)
+			}
+			t.Run(tt.name, f)
+		}
+	}
+}
+
+func TestLoaderStrict(t *testing.T) {
+	type app struct {
+		Port int    `env:"APP_PORT" flag:"port"`
+		Host string `env:"APP_HOST" flag:"host"`
+	}
+
+	test := []struct {
+		name string
+		envs map[string]string
+		args []string
+		want string
+		err  error
+	}{
+		{
+			name: "Unknown flag",
+			args: []string{"--prot=8080"},
+			want: "unknown flag: --prot (did you mean --port?)",
+			err:  config.ErrUnknownFlag,
+		},
+		{
+			name: "Unknown env",
+			envs: map[string]string{"APP_HSOT": "localhost"},
+			want: "unknown environment variable: APP_HSOT (did you mean APP_HOST?)",
+			err:  config.ErrUnknownEnv,
+		},
+	}
+
+	for _, tt := range test {
+		t.Logf("Given the need to test the strict mode with %s", tt.name)
+		{
+			os.Clearenv()
+			for k, v := range tt.envs {
+				os.Setenv(k, v)
+			}
+
+			f := func(t *testing.T) {
+				var cfg app
+				l := config.NewLoader(config.WithArgs(tt.args), config.WithStrict(), config.WithEnvPrefix("APP"))
+				err := l.Load(&cfg)
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("\t%s\tShould get the %v error: %v", failed, tt.err, err)
+				}
+				t.Logf("\t%s\tShould get the %v error.", success, tt.err)
+
+				if err.Error() != tt.want {
+					t.Fatalf("\t%s\tShould get the error %q, got %q", failed, tt.want, err.Error())
+				}
+				t.Logf("\t%s\tShould suggest the closest name.", success)
+			}
+			t.Run(tt.name, f)
+		}
+	}
+}
+
+func TestProcessBool(t *testing.T) {
+	type feature struct {
+		Verbose bool  `yaml:"verbose" flag:"verbose" default:"true"`
+		Cache   *bool `yaml:"cache" flag:"cache" default:"true"`
+		Debug   *bool `yaml:"debug" flag:"debug"`
+	}
+
+	yes, no := true, false
+
+	test := []struct {
+		name string
+		data string
+		args []string
+		want feature
+	}{
+		{
+			name: "Defaults",
+			want: feature{Verbose: true, Cache: &yes},
+		},
+		{
+			name: "Negated flags",
+			args: []string{"--no-verbose", "--no-debug"},
+			want: feature{Verbose: false, Cache: &yes, Debug: &no},
+		},
+		{
+			name: "Last occurrence wins",
+			args: []string{"--no-cache", "--cache", "--debug", "--no-debug"},
+			want: feature{Verbose: true, Cache: &yes, Debug: &no},
+		},
+		{
+			name: "Tri-state from yaml",
+			data: "cache: false",
+			want: feature{Verbose: true, Cache: &no},
+		},
+	}
+
+	for _, tt := range test {
+		t.Logf("Given the need to test boolean flags with %s", tt.name)
+		{
+			os.Clearenv()
+
+			f := func(t *testing.T) {
+				var cfg feature
+				l := config.NewLoader(config.WithArgs(tt.args), config.WithParsers(yaml.WithData([]byte(tt.data))))
+				if err := l.Load(&cfg); err != nil {
+					t.Fatalf("\t%s\tShould be able to load the feature struct: %v", failed, err)
+				}
+				t.Logf("\t%s\tShould be able to load the feature struct.", success)
+
+				if diff := cmp.Diff(tt.want, cfg); diff != "" {
+					t.Fatalf("\t%s\tShould get the expected config: %s", failed, diff)
+				}
+				t.Logf("\t%s\tShould get the expected config.", success)
+			}
+			t.Run(tt.name, f)
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	type global struct {
+		Verbose bool `flag:"verbose" shortFlag:"V"`
+	}
+	type serve struct {
+		Port int `flag:"port" shortFlag:"p" default:"8080"`
+	}
+	type migrate struct {
+		Steps int `flag:"steps"`
+	}
+
+	test := []struct {
+		name    string
+		args    []string
+		run     string
+		global  global
+		serve   serve
+		migrate migrate
+		err     error
+	}{
+		{
+			name:   "Global flags before the command",
+			args:   []string{"-V", "serve", "-p", "9090"},
+			run:    "serve",
+			global: global{Verbose: true},
+			serve:  serve{Port: 9090},
+		},
+		{
+			name:    "Global flags after the command",
+			args:    []string{"migrate", "--steps", "3", "--verbose"},
+			run:     "migrate",
+			global:  global{Verbose: true},
+			migrate: migrate{Steps: 3},
+		},
+		{
+			name: "Unknown command",
+			args: []string{"serv"},
+			err:  config.ErrUnknownCommand,
+		},
+		{
+			name: "Command help",
+			args: []string{"serve", "--help"},
+			err:  config.ErrHelp,
+		},
+	}
+
+	for _, tt := range test {
+		t.Logf("Given the need to test the subcommands with %s", tt.name)
+		{
+			os.Clearenv()
+
+			f := func(t *testing.T) {
+				var (
+					g   global
+					s   serve
+					m   migrate
+					run string
+					out strings.Builder
+				)
+
+				cmds := config.Commands{
+					Root: &g,
+					Commands: []config.Command{
+						{Name: "serve", Usage: "start the server", Config: &s, Run: func(ctx context.Context) error { run = "serve"; return nil }},
+						{Name: "migrate", Usage: "migrate the database", Config: &m, Run: func(ctx context.Context) error { run = "migrate"; return nil }},
+					},
+				}
+
+				err := cmds.Execute(context.Background(), config.WithArgs(tt.args), config.WithOutput(&out))
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("\t%s\tShould get the %v error: %v", failed, tt.err, err)
+				}
+				t.Logf("\t%s\tShould get the %v error.", success, tt.err)
+
+				if tt.err == config.ErrHelp && !strings.Contains(out.String(), "serve [options]") {
+					t.Fatalf("\t%s\tShould write the usage of the command: %s", failed, out.String())
+				}
+
+				if diff := cmp.Diff([]interface{}{tt.run, tt.global, tt.serve, tt.migrate}, []interface{}{run, g, s, m}); tt.err == nil && diff != "" {
+					t.Fatalf("\t%s\tShould run the command with the expected config: %s", failed, diff)
+				}
+				t.Logf("\t%s\tShould run the command with the expected config.", success)
+			}
+			t.Run(tt.name, f)
+		}
+	}
+}
+
+func TestCommandsParsers(t *testing.T) {
+	type tls struct {
+		Cert string `yaml:"cert"`
+		Key  string `yaml:"key"`
+		Port int    `yaml:"port" default:"443"`
+	}
+	type serve struct {
+		TLS *tls `yaml:"tls"`
+	}
+
+	test := []struct {
+		name string
+		args []string
+		want tls
+	}{
+		{
+			name: "the section from the yaml",
+			args: []string{"serve"},
+			want: tls{Cert: "c.pem", Port: 443},
+		},
+		{
+			name: "a flag of the section from the yaml",
+			args: []string{"serve", "--tls-key=override"},
+			want: tls{Cert: "c.pem", Key: "override", Port: 443},
+		},
+	}
+
+	for _, tt := range test {
+		t.Logf("Given the need to test the subcommands with the parsers and %s", tt.name)
+		{
+			os.Clearenv()
+
+			f := func(t *testing.T) {
+				var s serve
+				cmds := config.Commands{
+					Commands: []config.Command{
+						{Name: "serve", Config: &s, Run: func(ctx context.Context) error { return nil }},
+					},
+				}
+
+				err := cmds.Execute(context.Background(), config.WithArgs(tt.args), config.WithParsers(yaml.WithData([]byte("tls:\n  cert: c.pem\n"))))
+				if err != nil {
+					t.Fatalf("\t%s\tShould run the command: %v", failed, err)
+				}
+				if s.TLS == nil {
+					t.Fatalf("\t%s\tShould set the section from the yaml.", failed)
+				}
+				if diff := cmp.Diff(tt.want, *s.TLS); diff != "" {
+					t.Fatalf("\t%s\tShould apply the flags and defaults to the section from the yaml: %s", failed, diff)
+				}
+				t.Logf("\t%s\tShould apply the flags and defaults to the section from the yaml.", success)
+			}
+			t.Run(tt.name, f)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	type app struct {
+		LogLevel string `flag:"log-level" shortFlag:"l" oneof:"debug info warn error" default:"info" usage:"log level"`
+		Config   string `flag:"config" complete:"file" usage:"config file"`
+		Verbose  bool   `flag:"verbose"`
+	}
+
+	test := []struct {
+		shell string
+		want  []string
+	}{
+		{
+			shell: "bash",
+			want: []string{
+				"--log-level|-l)\n            COMPREPLY=($(compgen -W \"debug info warn error\" -- \"$cur\"))",
+				"--config)\n            COMPREPLY=($(compgen -f -- \"$cur\"))",
+				"--verbose --no-verbose",
+			},
+		},
+		{
+			shell: "zsh",
+			want: []string{
+				"'(-l --log-level)'{-l,--log-level}'[log level]:log-level:(debug info warn error)' \\",
+				"'--config[config file]:config:_files' \\",
+			},
+		},
+		{
+			shell: "fish",
+			want: []string{
+				"complete -c app -s l -l log-level -d 'log level' -x -a 'debug info warn error'",
+				"complete -c app -l config -d 'config file' -r -F",
+			},
+		},
+	}
+
+	for _, tt := range test {
+		t.Logf("Given the need to test the completion script for %s", tt.shell)
+		{
+			os.Clearenv()
+
+			f := func(t *testing.T) {
+				os.Args = []string{"/usr/bin/app"}
+
+				var out strings.Builder
+				var cfg app
+				l := config.NewLoader(config.WithArgs([]string{"--completion=" + tt.shell}), config.WithOutput(&out))
+				if err := l.Load(&cfg); !errors.Is(err, config.ErrCompletion) {
+					t.Fatalf("\t%s\tShould get the completion error: %v", failed, err)
+				}
+				t.Logf("\t%s\tShould get the completion error.", success)
+
+				for _, w := range tt.want {
+					if !strings.Contains(out.String(), w) {
+						t.Fatalf("\t%s\tShould contain %q in:\n%s", failed, w, out.String())
+					}
+				}
+				t.Logf("\t%s\tShould write the completion script.", success)
+			}
+			t.Run(tt.shell, f)
+		}
+	}
+
+	t.Logf("Given the need to test the oneof rule")
+	{
+		var cfg app
+		err := config.NewLoader(config.WithArgs([]string{"--log-level", "trace"})).Load(&cfg)
+		if err == nil || !strings.Contains(err.Error(), `"trace", must be one of: debug, info, warn, error`) {
+			t.Fatalf("\t%s\tShould reject the value out of the oneof list: %v", failed, err)
+		}
+		t.Logf("\t%s\tShould reject the value out of the oneof list.", success)
+	}
+}
+
+// upper is a custom decoder used to test the decoders of the leaf fields.
+type upper struct {
+	Value string
+}
+
+func (u *upper) Decode(val string) error {
+	u.Value = strings.ToUpper(val)
+	return nil
+}
+
+// label is a json.Unmarshaler backed by a string used to test the plain values passed as JSON strings.
+type label string
+
+func (l *label) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*l = label(strings.ToLower(s))
+	return nil
+}
+
+// weight is a json.Unmarshaler backed by an int used to test the JSON numbers passed as they are.
+type weight int
+
+func (w *weight) UnmarshalJSON(b []byte) error {
+	var n int
+	if err := json.Unmarshal(b, &n); err != nil {
+		return err
+	}
+	*w = weight(n * 10)
+	return nil
+}
+
+func TestProcessDecoder(t *testing.T) {
+	type keys struct {
+		Secret  config.Base64Bytes         `env:"SECRET"`
+		Key     config.HexBytes            `env:"KEY"`
+		KeyPtr  *config.HexBytes           `env:"KEY_PTR"`
+		KeyList []config.HexBytes          `env:"KEY_LIST"`
+		KeyMap  map[string]config.HexBytes `env:"KEY_MAP"`
+		Name    upper                      `env:"NAME"`
+		Names   []upper                    `env:"NAMES"`
+		Created time.Time                  `env:"CREATED"`
+		Expires *time.Time                 `env:"EXPIRES"`
+	}
+
+	envs := map[string]string{
+		"SECRET":   "aGVsbG8=",
+		"KEY":      "cafe",
+		"KEY_PTR":  "beef",
+		"KEY_LIST": "01,02",
+		"KEY_MAP":  "a:ff",
+		"NAME":     "foo",
+		"NAMES":    "a,b",
+		"CREATED":  "2024-01-02T03:04:05Z",
+		"EXPIRES":  "2025-01-02T03:04:05Z",
+	}
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	expires := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	keyPtr := config.HexBytes{0xbe, 0xef}
+
+	want := keys{
+		Secret:  config.Base64Bytes("hello"),
+		Key:     config.HexBytes{0xca, 0xfe},
+		KeyPtr:  &keyPtr,
+		KeyList: []config.HexBytes{{0x01}, {0x02}},
+		KeyMap:  map[string]config.HexBytes{"a": {0xff}},
+		Name:    upper{Value: "FOO"},
+		Names:   []upper{{Value: "A"}, {Value: "B"}},
+		Created: created,
+		Expires: &expires,
+	}
+
+	t.Logf("Given the need to test the custom decoders of the leaf fields")
+	{
+		os.Clearenv()
+		for k, v := range envs {
+			os.Setenv(k, v)
+		}
+
+		var cfg keys
+		if err := config.NewLoader(config.WithArgs(nil)).Load(&cfg); err != nil {
+			t.Fatalf("\t%s\tShould be able to load the keys struct: %v", failed, err)
+		}
+		t.Logf("\t%s\tShould be able to load the keys struct.", success)
+
+		if diff := cmp.Diff(want, cfg); diff != "" {
+			t.Fatalf("\t%s\tShould decode the values with the custom decoders: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould decode the values with the custom decoders.", success)
+	}
+
+	t.Logf("Given the need to test the values of the json.Unmarshaler types")
+	{
+		type labels struct {
+			Name   label   `env:"NAME"`
+			Code   label   `env:"CODE"`
+			Flag   label   `env:"FLAG"`
+			Null   label   `env:"NULL"`
+			Quoted label   `env:"QUOTED"`
+			Weight weight  `env:"WEIGHT"`
+			Tags   []label `env:"TAGS"`
+		}
+
+		os.Clearenv()
+		os.Setenv("NAME", "Web")
+		os.Setenv("CODE", "123")
+		os.Setenv("FLAG", "TRUE")
+		os.Setenv("NULL", "null")
+		os.Setenv("QUOTED", `"A,B"`)
+		os.Setenv("WEIGHT", "3")
+		os.Setenv("TAGS", "1,x")
+
+		var cfg labels
+		if err := config.NewLoader(config.WithArgs(nil)).Load(&cfg); err != nil {
+			t.Fatalf("\t%s\tShould be able to load the plain values of the json.Unmarshaler types: %v", failed, err)
+		}
+
+		want := labels{Name: "web", Code: "123", Flag: "true", Null: "null", Quoted: "a,b", Weight: 30, Tags: []label{"1", "x"}}
+		if diff := cmp.Diff(want, cfg); diff != "" {
+			t.Fatalf("\t%s\tShould pass the plain values as JSON strings: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould pass the plain values as JSON strings and the JSON numbers as they are.", success)
+
+		os.Setenv("WEIGHT", "heavy")
+		if err := config.NewLoader(config.WithArgs(nil)).Load(&cfg); err == nil || !strings.Contains(err.Error(), "cannot unmarshal string into Go value of type int") {
+			t.Fatalf("\t%s\tShould report the value rejected by the type, got %v", failed, err)
+		}
+		t.Logf("\t%s\tShould report the value rejected by the type.", success)
+	}
+}
+
+func TestRegisterType(t *testing.T) {
+	type limits struct {
+		Ratio *big.Float   `env:"RATIO"`
+		Mask  net.IPMask   `env:"MASK"`
+		Masks []net.IPMask `env:"MASKS"`
+	}
+
+	config.RegisterType(func(s string) (*big.Float, error) {
+		f, _, err := big.ParseFloat(s, 10, 64, big.ToNearestEven)
+		return f, err
+	})
+	config.RegisterFormatter(func(f *big.Float) string {
+		return f.Text('f', 3)
+	})
+
+	parseMask := func(s string) (net.IPMask, error) {
+		ones, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, err
+		}
+		return net.CIDRMask(ones, 32), nil
+	}
+
+	t.Logf("Given the need to test the registered types")
+	{
+		os.Clearenv()
+		os.Setenv("RATIO", "0.25")
+		os.Setenv("MASK", "24")
+		os.Setenv("MASKS", "8,16")
+
+		var cfg limits
+		if err := config.NewLoader(config.WithArgs(nil), config.WithType(parseMask)).Load(&cfg); err != nil {
+			t.Fatalf("\t%s\tShould be able to load the limits struct: %v", failed, err)
+		}
+		t.Logf("\t%s\tShould be able to load the limits struct.", success)
+
+		if cfg.Ratio.String() != "0.25" || cfg.Mask.String() != "ffffff00" || len(cfg.Masks) != 2 || cfg.Masks[1].String() != "ffff0000" {
+			t.Fatalf("\t%s\tShould decode the values with the registered types: %v %v %v", failed, cfg.Ratio, cfg.Mask, cfg.Masks)
+		}
+		t.Logf("\t%s\tShould decode the values with the registered types.", success)
+
+		os.Args = []string{"config.test"}
+		msg, err := config.StartupMessage(&cfg)
+		if err != nil || !strings.Contains(msg, "--ratio: 0.250\n") {
+			t.Fatalf("\t%s\tShould format the value with the registered formatter: %v\n%s", failed, err, msg)
+		}
+		t.Logf("\t%s\tShould format the value with the registered formatter.", success)
+	}
+}
+
+func TestWithTypeStruct(t *testing.T) {
+	type pair struct {
+		A, B string
+	}
+
+	type app struct {
+		P     pair   `env:"P"`
+		Pairs []pair `env:"PAIRS"`
+	}
+
+	parsePair := func(s string) (pair, error) {
+		a, b, ok := strings.Cut(s, "/")
+		if !ok {
+			return pair{}, errors.New("invalid pair: " + s)
+		}
+		return pair{A: a, B: b}, nil
+	}
+
+	t.Logf("Given the need to test the struct types registered for the Loader")
+	{
+		os.Clearenv()
+		os.Setenv("P", "x/y")
+		os.Setenv("PAIRS", "a/b,c/d")
+
+		var cfg app
+		if err := config.NewLoader(config.WithArgs(nil), config.WithType(parsePair)).Load(&cfg); err != nil {
+			t.Fatalf("\t%s\tShould be able to load the app struct: %v", failed, err)
+		}
+		t.Logf("\t%s\tShould be able to load the app struct.", success)
+
+		want := app{P: pair{A: "x", B: "y"}, Pairs: []pair{{A: "a", B: "b"}, {A: "c", B: "d"}}}
+		if diff := cmp.Diff(want, cfg); diff != "" {
+			t.Fatalf("\t%s\tShould decode the struct fields with the registered type: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould decode the struct fields with the registered type.", success)
+	}
+}
+
+func TestBuiltinTypes(t *testing.T) {
+	type infra struct {
+		Endpoint url.URL        `env:"ENDPOINT"`
+		Proxy    *url.URL       `env:"PROXY"`
+		IP       net.IP         `env:"IP"`
+		Network  net.IPNet      `env:"NETWORK"`
+		Addr     netip.Addr     `env:"ADDR"`
+		Prefix   netip.Prefix   `env:"PREFIX"`
+		Date     time.Time      `env:"DATE" layout:"2006-01-02"`
+		Zone     *time.Location `env:"ZONE"`
+		Level    slog.Level     `env:"LEVEL"`
+		Mode     os.FileMode    `env:"MODE"`
+		Pattern  *regexp.Regexp `env:"PATTERN"`
+	}
+
+	envs := map[string]string{
+		"ENDPOINT": "https://example.com/api",
+		"PROXY":    "http://proxy:3128",
+		"IP":       "10.0.0.1",
+		"NETWORK":  "10.0.0.0/8",
+		"ADDR":     "::1",
+		"PREFIX":   "192.168.0.0/16",
+		"DATE":     "2024-02-29",
+		"ZONE":     "UTC",
+		"LEVEL":    "warn",
+		"MODE":     "0640",
+		"PATTERN":  "^a+$",
+	}
+
+	want := []string{
+		"--endpoint: https://example.com/api\n",
+		"--proxy: http://proxy:3128\n",
+		"--ip: 10.0.0.1\n",
+		"--network: 10.0.0.0/8\n",
+		"--addr: ::1\n",
+		"--prefix: 192.168.0.0/16\n",
+		"--date: 2024-02-29\n",
+		"--zone: UTC\n",
+		"--level: WARN\n",
+		"--mode: 0640\n",
+		"--pattern: ^a+$\n",
+	}
+
+	t.Logf("Given the need to test the built-in infrastructure types")
+	{
+		os.Clearenv()
+		for k, v := range envs {
+			os.Setenv(k, v)
+		}
+
+		var cfg infra
+		if err := config.NewLoader(config.WithArgs(nil)).Load(&cfg); err != nil {
+			t.Fatalf("\t%s\tShould be able to load the infra struct: %v", failed, err)
+		}
+		t.Logf("\t%s\tShould be able to load the infra struct.", success)
+
+		if !cfg.Pattern.MatchString("aaa") || cfg.Level != slog.LevelWarn || cfg.Mode != 0640 {
+			t.Fatalf("\t%s\tShould decode the values: %+v", failed, cfg)
+		}
+
+		os.Args = []string{"config.test"}
+		msg, err := config.StartupMessage(&cfg)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to build the startup message: %v", failed, err)
+		}
+
+		for _, w := range want {
+			if !strings.Contains(msg, w) {
+				t.Fatalf("\t%s\tShould contain %q in:\n%s", failed, w, msg)
+			}
+		}
+		t.Logf("\t%s\tShould format the values in the startup message.", success)
+	}
+}
+
+func TestUnits(t *testing.T) {
+	type limits struct {
+		Cache  config.ByteSize `env:"CACHE" default:"512KiB" min:"1KiB" max:"1GiB"`
+		Upload config.ByteSize `env:"UPLOAD" default:"10MB"`
+		Big    config.ByteSize `env:"BIG" default:"1.5GiB"`
+		Rate   config.Rate     `env:"RATE" default:"100/s" max:"6000/min"`
+		Burst  config.Rate     `env:"BURST" default:"5000/min"`
+	}
+
+	t.Logf("Given the need to test the byte size and rate types")
+	{
+		os.Clearenv()
+
+		var cfg limits
+		if err := config.NewLoader(config.WithArgs(nil)).Load(&cfg); err != nil {
+			t.Fatalf("\t%s\tShould be able to load the limits struct: %v", failed, err)
+		}
+		t.Logf("\t%s\tShould be able to load the limits struct.", success)
+
+		want := limits{
+			Cache:  512 * config.KiB,
+			Upload: 10 * config.MB,
+			Big:    1536 * config.MiB,
+			Rate:   config.Rate{Count: 100, Per: time.Second},
+			Burst:  config.Rate{Count: 5000, Per: time.Minute},
+		}
+		if diff := cmp.Diff(want, cfg); diff != "" {
+			t.Fatalf("\t%s\tShould decode the human-readable values: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould decode the human-readable values.", success)
+
+		got := []string{cfg.Cache.String(), cfg.Upload.String(), cfg.Big.String(), cfg.Rate.String(), cfg.Burst.String()}
+		if diff := cmp.Diff([]string{"512KiB", "10MB", "1.5GiB", "100/s", "5000/min"}, got); diff != "" {
+			t.Fatalf("\t%s\tShould round-trip the values: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould round-trip the values.", success)
+	}
+
+	t.Logf("Given the need to test the min and max rules in human units")
+	{
+		test := map[string]string{
+			"CACHE": "invalid value for field Cache: 2GiB, must be at most 1GiB",
+			"RATE":  "invalid value for field Rate: 101/s, must be at most 6000/min",
+		}
+		values := map[string]string{"CACHE": "2GiB", "RATE": "101/s"}
+
+		for env, want := range test {
+			os.Clearenv()
+			os.Setenv(env, values[env])
+
+			var cfg limits
+			err := config.NewLoader(config.WithArgs(nil)).Load(&cfg)
+			if err == nil || err.Error() != want {
+				t.Fatalf("\t%s\tShould get the error %q, got %v", failed, want, err)
+			}
+			t.Logf("\t%s\tShould get the error %q.", success, want)
+		}
+	}
+}
+
+func TestValidateExplicitZero(t *testing.T) {
+	type limits struct {
+		Workers int             `yaml:"workers" env:"WORKERS" min:"1"`
+		Cache   config.ByteSize `yaml:"cache" env:"CACHE" min:"1KiB"`
+		Port    *int            `yaml:"port" env:"PORT" min:"1" max:"65535"`
+	}
+
+	test := []struct {
+		name string
+		env  map[string]string
+		yaml string
+		err  string
+	}{
+		{
+			name: "unset fields",
+		},
+		{
+			name: "zero from the environment",
+			env:  map[string]string{"WORKERS": "0"},
+			err:  "invalid value for field Workers: 0, must be at least 1",
+		},
+		{
+			name: "zero byte size from the environment",
+			env:  map[string]string{"CACHE": "0"},
+			err:  "invalid value for field Cache: 0B, must be at least 1KiB",
+		},
+		{
+			name: "zero from the yaml",
+			yaml: "workers: 0\n",
+			err:  "invalid value for field Workers: 0, must be at least 1",
+		},
+		{
+			name: "null pointer from the yaml",
+			yaml: "port: null\n",
+		},
+		{
+			name: "pointer from the yaml",
+			yaml: "port: 70000\n",
+			err:  "invalid value for field Port: 70000, must be at most 65535",
+		},
+	}
+
+	for _, tt := range test {
+		t.Logf("Given the need to test the validation of the explicit zero values with %s", tt.name)
+		{
+			os.Clearenv()
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+			}
+
+			f := func(t *testing.T) {
+				var cfg limits
+				err := config.NewLoader(config.WithArgs(nil), config.WithParsers(yaml.WithData([]byte(tt.yaml)))).Load(&cfg)
+				if tt.err == "" && err != nil {
+					t.Fatalf("\t%s\tShould not validate the unset fields: %v", failed, err)
+				}
+				if tt.err != "" && (err == nil || err.Error() != tt.err) {
+					t.Fatalf("\t%s\tShould get the error %q, got %v", failed, tt.err, err)
+				}
+				t.Logf("\t%s\tShould validate only the values which are set.", success)
+			}
+			t.Run(tt.name, f)
+		}
+	}
+}
+
+func TestStructCollections(t *testing.T) {
+	type upstream struct {
+		Host    string `yaml:"host" required:"true"`
+		Port    int    `yaml:"port" default:"80"`
+		Enabled bool   `yaml:"enabled"`
+	}
+
+	type backend struct {
+		URL     string `yaml:"url" env:"URL"`
+		Weight  int    `yaml:"weight" default:"1"`
+		Primary bool   `yaml:"primary"`
+	}
+
+	type proxy struct {
+		Upstreams []upstream         `yaml:"upstreams"`
+		Backends  map[string]backend `yaml:"backends"`
+		Mirrors   []*upstream        `yaml:"mirrors"`
+	}
+
+	data := `
+upstreams:
+  - host: a.local
+    port: 8080
+  - host: b.local
+backends:
+  primary:
+    url: http://primary
+    weight: 5
+`
+
+	t.Logf("Given the need to test slices and maps of structs with indexed names")
+	{
+		os.Clearenv()
+		os.Setenv("UPSTREAMS_1_PORT", "9090")
+		os.Setenv("UPSTREAMS_7_HOST", "c.local")
+		os.Setenv("BACKENDS_PRIMARY_WEIGHT", "10")
+		os.Setenv("BACKENDS_CANARY_EU_URL", "http://canary")
+
+		args := []string{
+			"--upstreams.0.host", "override.local",
+			"--upstreams.7.enabled", "--backends.canary_eu.primary",
+			"--mirrors.0.host=m.local",
+		}
+
+		var cfg proxy
+		l := config.NewLoader(config.WithArgs(args), config.WithStrict(), config.WithParsers(yaml.WithData([]byte(data))))
+		if err := l.Load(&cfg); err != nil {
+			t.Fatalf("\t%s\tShould be able to load the collections: %v", failed, err)
+		}
+		t.Logf("\t%s\tShould be able to load the collections.", success)
+
+		want := proxy{
+			Upstreams: []upstream{
+				{Host: "override.local", Port: 8080},
+				{Host: "b.local", Port: 9090},
+				{Host: "c.local", Port: 80, Enabled: true},
+			},
+			Backends: map[string]backend{
+				"primary":   {URL: "http://primary", Weight: 10},
+				"canary_eu": {URL: "http://canary", Weight: 1, Primary: true},
+			},
+			Mirrors: []*upstream{{Host: "m.local", Port: 80}},
+		}
+		if diff := cmp.Diff(want, cfg); diff != "" {
+			t.Fatalf("\t%s\tShould merge the file with the indexed values: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould merge the file with the indexed values.", success)
+
+		msg, err := config.StartupMessage(&cfg)
+		if err != nil || !strings.Contains(msg, "--upstreams.2.host: c.local\n") {
+			t.Fatalf("\t%s\tShould list the elements in the startup message: %v\n%s", failed, err, msg)
+		}
+		t.Logf("\t%s\tShould list the elements in the startup message.", success)
+
+		usage, err := config.UsageMessage(&cfg)
+		if err != nil || !strings.Contains(usage, "--upstreams.<n>.host") || !strings.Contains(usage, "$BACKENDS_<KEY>_URL") {
+			t.Fatalf("\t%s\tShould list the element fields in the usage message: %v\n%s", failed, err, usage)
+		}
+		t.Logf("\t%s\tShould list the element fields in the usage message.", success)
+	}
+
+	t.Logf("Given the need to test the rules of the elements")
+	{
+		os.Clearenv()
+		os.Setenv("UPSTREAMS_0_PORT", "8080")
+
+		var cfg proxy
+		err := config.NewLoader(config.WithArgs(nil)).Load(&cfg)
+		if err == nil || err.Error() != "required field not set: Upstreams_0_Host" {
+			t.Fatalf("\t%s\tShould enforce the required element field, got %v", failed, err)
+		}
+		t.Logf("\t%s\tShould enforce the required element field.", success)
+
+		os.Clearenv()
+		err = config.NewLoader(config.WithArgs([]string{"--upstreams.0.hots", "x"}), config.WithStrict()).Load(&cfg)
+		if !errors.Is(err, config.ErrUnknownFlag) {
+			t.Fatalf("\t%s\tShould reject the unknown element flag in strict mode, got %v", failed, err)
+		}
+		t.Logf("\t%s\tShould reject the unknown element flag in strict mode.", success)
+
+		os.Clearenv()
+		os.Setenv("UPSTREAMS_01_HOST", "a")
+		os.Setenv("UPSTREAMS_1_HOST", "b")
+		err = config.NewLoader(config.WithArgs(nil)).Load(&cfg)
+		if err == nil || err.Error() != `invalid index "01" for field Upstreams` {
+			t.Fatalf("\t%s\tShould reject the non-canonical index, got %v", failed, err)
+		}
+		t.Logf("\t%s\tShould reject the non-canonical index.", success)
+	}
+
+	t.Logf("Given the need to test the keys of the map elements in another case")
+	{
+		os.Clearenv()
+		os.Setenv("BACKENDS_PRIMARY_WEIGHT", "7")
+
+		var cfg proxy
+		args := []string{"--backends.primary.url=http://override", "--backends.Canary.url=http://canary", "--backends.canary.primary"}
+		l := config.NewLoader(config.WithArgs(args), config.WithStrict(), config.WithParsers(yaml.WithData([]byte("backends:\n  Primary:\n    url: http://primary\n"))))
+		if err := l.Load(&cfg); err != nil {
+			t.Fatalf("\t%s\tShould accept the flags of the keys in another case: %v", failed, err)
+		}
+
+		want := map[string]backend{
+			"Primary": {URL: "http://override", Weight: 7},
+			"Canary":  {URL: "http://canary", Weight: 1, Primary: true},
+		}
+		if diff := cmp.Diff(want, cfg.Backends); diff != "" {
+			t.Fatalf("\t%s\tShould match the keys case-insensitively: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould match the keys of the flags case-insensitively like the environment variables.", success)
+	}
+}
+
+func TestOptionalSections(t *testing.T) {
+	type client struct {
+		CA string `yaml:"ca"`
+	}
+
+	type tls struct {
+		Cert   string  `yaml:"cert" required:"true"`
+		Key    string  `yaml:"key" required:"true"`
+		MinVer string  `yaml:"min_ver" default:"1.2"`
+		Client *client `yaml:"client"`
+	}
+
+	type server struct {
+		Port int  `yaml:"port" default:"8080"`
+		TLS  *tls `yaml:"tls"`
+	}
+
+	t.Logf("Given the need to test the optional sections")
+	{
+		os.Clearenv()
+
+		var cfg server
+		if err := config.NewLoader(config.WithArgs(nil)).Load(&cfg); err != nil {
+			t.Fatalf("\t%s\tShould not enforce the required fields of an unset section: %v", failed, err)
+		}
+		if cfg.TLS != nil {
+			t.Fatalf("\t%s\tShould leave the unset section nil, got %+v", failed, cfg.TLS)
+		}
+		t.Logf("\t%s\tShould leave the unset section nil.", success)
+
+		msg, err := config.StartupMessage(&cfg)
+		if err != nil || strings.Contains(msg, "tls") {
+			t.Fatalf("\t%s\tShould leave the unset section out of the startup message: %v\n%s", failed, err, msg)
+		}
+		t.Logf("\t%s\tShould leave the unset section out of the startup message.", success)
+
+		os.Setenv("TLS_CERT", "cert.pem")
+		os.Setenv("TLS_KEY", "key.pem")
+		args := []string{"--tls-client-ca", "ca.pem"}
+
+		cfg = server{}
+		if err := config.NewLoader(config.WithArgs(args)).Load(&cfg); err != nil {
+			t.Fatalf("\t%s\tShould be able to load the set sections: %v", failed, err)
+		}
+
+		want := server{Port: 8080, TLS: &tls{Cert: "cert.pem", Key: "key.pem", MinVer: "1.2", Client: &client{CA: "ca.pem"}}}
+		if diff := cmp.Diff(want, cfg); diff != "" {
+			t.Fatalf("\t%s\tShould allocate the set sections: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould allocate the set sections.", success)
+
+		os.Unsetenv("TLS_KEY")
+		cfg = server{}
+		err = config.NewLoader(config.WithArgs(nil)).Load(&cfg)
+		if err == nil || err.Error() != "required field not set: TLS_Key" {
+			t.Fatalf("\t%s\tShould enforce the required fields of a set section, got %v", failed, err)
+		}
+		t.Logf("\t%s\tShould enforce the required fields of a set section.", success)
+	}
+
+	t.Logf("Given the need to test the sections given by the parsers")
+	{
+		os.Clearenv()
+		os.Setenv("TLS_KEY", "env.pem")
+
+		var cfg server
+		l := config.NewLoader(config.WithArgs(nil), config.WithParsers(yaml.WithData([]byte("tls:\n  cert: file.pem\n"))))
+		if err := l.Load(&cfg); err != nil {
+			t.Fatalf("\t%s\tShould be able to load the section from the file: %v", failed, err)
+		}
+
+		want := server{Port: 8080, TLS: &tls{Cert: "file.pem", Key: "env.pem", MinVer: "1.2"}}
+		if diff := cmp.Diff(want, cfg); diff != "" {
+			t.Fatalf("\t%s\tShould merge the section from the file: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould merge the section from the file.", success)
+	}
+}
+
+func TestProcessJSON(t *testing.T) {
+	type route struct {
+		Path   string `json:"path"`
+		Weight int    `json:"weight"`
+	}
+
+	type limits struct {
+		Burst int `json:"burst"`
+	}
+
+	type app struct {
+		Routes []route              `env:"ROUTES"`
+		Tags   []string             `env:"TAGS"`
+		Groups map[string][]string  `env:"GROUPS" format:"json"`
+		Limits limits               `env:"LIMITS" format:"json"`
+		Labels map[string]string    `env:"LABELS"`
+		Nested []map[string]float64 `flag:"nested"`
+	}
+
+	t.Logf("Given the need to test the JSON literal values")
+	{
+		os.Clearenv()
+		os.Setenv("ROUTES", `[{"path":"/a","weight":3},{"path":"/b,c","weight":1}]`)
+		os.Setenv("TAGS", `["a,b", "c"]`)
+		os.Setenv("GROUPS", `{"admins":["ann","bob"]}`)
+		os.Setenv("LIMITS", `{"burst":10}`)
+		os.Setenv("LABELS", "env:prod,team:core")
+
+		var cfg app
+		args := []string{"--nested", `[{"x":1.5},{"y":2}]`}
+		if err := config.NewLoader(config.WithArgs(args)).Load(&cfg); err != nil {
+			t.Fatalf("\t%s\tShould be able to load the JSON values: %v", failed, err)
+		}
+
+		want := app{
+			Routes: []route{{Path: "/a", Weight: 3}, {Path: "/b,c", Weight: 1}},
+			Tags:   []string{"a,b", "c"},
+			Groups: map[string][]string{"admins": {"ann", "bob"}},
+			Limits: limits{Burst: 10},
+			Labels: map[string]string{"env": "prod", "team": "core"},
+			Nested: []map[string]float64{{"x": 1.5}, {"y": 2}},
+		}
+		if diff := cmp.Diff(want, cfg); diff != "" {
+			t.Fatalf("\t%s\tShould decode the JSON values: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould decode the JSON values.", success)
+	}
+
+	t.Logf("Given the need to test the errors of the JSON values")
+	{
+		test := []struct {
+			env   string
+			value string
+			want  string
+		}{
+			{"ROUTES", `[{"path":"/a","weight":"3"}]`, "error processing field: Routes from environment variable ROUTES, error: invalid JSON at offset 26: "},
+			{"ROUTES", `[{"path":"/a",}]`, "error processing field: Routes from environment variable ROUTES, error: invalid JSON at offset 15: "},
+			{"GROUPS", `{"admins":["ann",]}`, "error processing field: Groups from environment variable GROUPS, error: invalid JSON at offset 18: "},
+			{"LABELS", `{"env":"prod",}`, "error processing field: Labels from environment variable LABELS, error: invalid JSON at offset 15: "},
+		}
+
+		for _, tt := range test {
+			os.Clearenv()
+			os.Setenv(tt.env, tt.value)
+
+			var cfg app
+			err := config.NewLoader(config.WithArgs(nil)).Load(&cfg)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Fatalf("\t%s\tShould get the error %q, got %v", failed, tt.want, err)
+			}
+			t.Logf("\t%s\tShould get the error %q.", success, tt.want)
+		}
+	}
+
+	t.Logf("Given the need to test the values starting with a bracket which are not JSON")
+	{
+		type peers struct {
+			Peers []string `env:"PEERS"`
+		}
+
+		os.Clearenv()
+		test := map[string][]string{
+			"[::1]:7000,[::1]:7001":       {"[::1]:7000", "[::1]:7001"},
+			"[fe80::1%eth0]:7000":         {"[fe80::1%eth0]:7000"},
+			"[2001:db8::1],[2001:db8::2]": {"[2001:db8::1]", "[2001:db8::2]"},
+		}
+
+		for value, want := range test {
+			os.Clearenv()
+			os.Setenv("PEERS", value)
+
+			var cfg peers
+			if err := config.NewLoader(config.WithArgs(nil)).Load(&cfg); err != nil {
+				t.Fatalf("\t%s\tShould be able to load the bracketed IPv6 addresses %s: %v", failed, value, err)
+			}
+			if diff := cmp.Diff(want, cfg.Peers); diff != "" {
+				t.Fatalf("\t%s\tShould split the bracketed IPv6 addresses %s with the delimiter: %s", failed, value, diff)
+			}
+			t.Logf("\t%s\tShould split the bracketed IPv6 addresses %s with the delimiter.", success, value)
+		}
+	}
+}
+
+func TestDelimiters(t *testing.T) {
+	type services struct {
+		Endpoints map[string]string `env:"ENDPOINTS" kvsep:"=" delim:";"`
+		Hosts     []string          `env:"HOSTS"`
+		Weights   map[string]int    `env:"WEIGHTS"`
+	}
+
+	t.Logf("Given the need to test the delimiter and the separator of the values")
+	{
+		os.Clearenv()
+		os.Setenv("ENDPOINTS", "svc=http://host:80/a,b;db=postgres://db:5432")
+		os.Setenv("HOSTS", "a.local|b.local")
+		os.Setenv("WEIGHTS", "a=1|b=2")
+
+		var cfg services
+		l := config.NewLoader(config.WithArgs(nil), config.WithDelimiter("|"), config.WithSeparator("="))
+		if err := l.Load(&cfg); err != nil {
+			t.Fatalf("\t%s\tShould be able to load the values: %v", failed, err)
+		}
+
+		want := services{
+			Endpoints: map[string]string{"svc": "http://host:80/a,b", "db": "postgres://db:5432"},
+			Hosts:     []string{"a.local", "b.local"},
+			Weights:   map[string]int{"a": 1, "b": 2},
+		}
+		if diff := cmp.Diff(want, cfg); diff != "" {
+			t.Fatalf("\t%s\tShould split the values with the delimiters: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould split the values with the delimiters.", success)
+
+		msg, err := config.StartupMessage(&cfg, config.WithDelimiter("|"), config.WithSeparator("="))
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to build the startup message: %v", failed, err)
+		}
+
+		for _, w := range []string{
+			"--endpoints: db=postgres://db:5432;svc=http://host:80/a,b\n",
+			"--hosts: a.local|b.local\n",
+			"--weights: a=1|b=2\n",
+		} {
+			if !strings.Contains(msg, w) {
+				t.Fatalf("\t%s\tShould contain %q in:\n%s", failed, w, msg)
+			}
+		}
+		t.Logf("\t%s\tShould format the values with the same delimiters.", success)
+	}
+}
+
+func TestProcessArraysAndNested(t *testing.T) {
+	type shapes struct {
+		ID      [4]byte             `env:"ID"`
+		Point   [3]int              `env:"POINT"`
+		Signal  complex128          `env:"SIGNAL"`
+		Small   complex64           `env:"SMALL"`
+		Matrix  [][]string          `env:"MATRIX"`
+		Groups  map[string][]string `env:"GROUPS"`
+		Ranges  [][]int             `env:"RANGES" delim:";"`
+		Batches [][]string          `env:"BATCHES" subdelim:"|"`
+	}
+
+	t.Logf("Given the need to test arrays, complex numbers and nested collections")
+	{
+		os.Clearenv()
+		os.Setenv("ID", "abcd")
+		os.Setenv("POINT", "1,2,3")
+		os.Setenv("SIGNAL", "1.5+2i")
+		os.Setenv("SMALL", "(3-1i)")
+		os.Setenv("MATRIX", "a;b,c;d")
+		os.Setenv("GROUPS", "admins:ann;bob,users:carl")
+		os.Setenv("RANGES", "1,2;3,4")
+		os.Setenv("BATCHES", "a|b,c")
+
+		var cfg shapes
+		if err := config.NewLoader(config.WithArgs(nil)).Load(&cfg); err != nil {
+			t.Fatalf("\t%s\tShould be able to load the values: %v", failed, err)
+		}
+
+		want := shapes{
+			ID:      [4]byte{'a', 'b', 'c', 'd'},
+			Point:   [3]int{1, 2, 3},
+			Signal:  complex(1.5, 2),
+			Small:   complex(3, -1),
+			Matrix:  [][]string{{"a", "b"}, {"c", "d"}},
+			Groups:  map[string][]string{"admins": {"ann", "bob"}, "users": {"carl"}},
+			Ranges:  [][]int{{1, 2}, {3, 4}},
+			Batches: [][]string{{"a", "b"}, {"c"}},
+		}
+		if diff := cmp.Diff(want, cfg); diff != "" {
+			t.Fatalf("\t%s\tShould decode the values: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould decode the values.", success)
+
+		msg, err := config.StartupMessage(&cfg)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to build the startup message: %v", failed, err)
+		}
+
+		for _, w := range []string{
+			"--id: abcd\n",
+			"--point: 1,2,3\n",
+			"--signal: (1.5+2i)\n",
+			"--matrix: a;b,c;d\n",
+			"--groups: admins:ann;bob,users:carl\n",
+			"--ranges: 1,2;3,4\n",
+			"--batches: a|b,c\n",
+		} {
+			if !strings.Contains(msg, w) {
+				t.Fatalf("\t%s\tShould contain %q in:\n%s", failed, w, msg)
+			}
+		}
+		t.Logf("\t%s\tShould format the values so they can be decoded back.", success)
+	}
+
+	t.Logf("Given the need to test the length of the arrays")
+	{
+		test := map[string]string{
+			"ID":    "error processing field: ID from environment variable ID, error: invalid length of [4]uint8: 3 bytes, must be 4",
+			"POINT": "error processing field: Point from environment variable POINT, error: invalid length of [3]int: 2 elements, must be 3",
+		}
+		values := map[string]string{"ID": "abc", "POINT": "1,2"}
+
+		for env, want := range test {
+			os.Clearenv()
+			os.Setenv(env, values[env])
+
+			var cfg shapes
+			err := config.NewLoader(config.WithArgs(nil)).Load(&cfg)
+			if err == nil || err.Error() != want {
+				t.Fatalf("\t%s\tShould get the error %q, got %v", failed, want, err)
+			}
+			t.Logf("\t%s\tShould get the error %q.", success, want)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	type service struct {
+		Level string `yaml:"level" env:"LEVEL" oneof:"debug info warn"`
+		Port  int    `yaml:"port" env:"PORT" default:"8080"`
+	}
+
+	t.Logf("Given the need to test reloading the configuration")
+	{
+		os.Clearenv()
+		path := t.TempDir() + "/config.yaml"
+		if err := os.WriteFile(path, []byte("level: info\n"), 0o600); err != nil {
+			t.Fatalf("\t%s\tShould be able to write the config file: %v", failed, err)
+		}
+
+		errs := make(chan error, 1)
+		w, err := config.NewWatcher[service](
+			config.WithArgs(nil),
+			config.WithParsers(yaml.File(path)),
+			config.WithWatchInterval(5*time.Millisecond),
+			config.WithReloadError(func(err error) { errs <- err }),
+		)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create the watcher: %v", failed, err)
+		}
+		if got := w.Value().Load(); got != (service{Level: "info", Port: 8080}) {
+			t.Fatalf("\t%s\tShould load the initial configuration, got %+v", failed, got)
+		}
+		t.Logf("\t%s\tShould load the initial configuration.", success)
+
+		changes := make(chan [2]service, 1)
+		w.Value().Subscribe(func(old, new service) {
+			changes <- [2]service{old, new}
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go w.Run(ctx)
+
+		// wait for the first poll, so the change is seen by the watcher
+		time.Sleep(20 * time.Millisecond)
+		if err := os.WriteFile(path, []byte("level: debug\nport: 9090\n"), 0o600); err != nil {
+			t.Fatalf("\t%s\tShould be able to write the config file: %v", failed, err)
+		}
+
+		select {
+		case c := <-changes:
+			want := [2]service{{Level: "info", Port: 8080}, {Level: "debug", Port: 9090}}
+			if c != want {
+				t.Fatalf("\t%s\tShould notify the change, got %+v", failed, c)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("\t%s\tShould reload the changed file.", failed)
+		}
+		t.Logf("\t%s\tShould reload the changed file and notify the subscribers.", success)
+
+		if err := os.WriteFile(path, []byte("level: verbose\n"), 0o600); err != nil {
+			t.Fatalf("\t%s\tShould be able to write the config file: %v", failed, err)
+		}
+
+		select {
+		case err := <-errs:
+			if !strings.Contains(err.Error(), "must be one of") {
+				t.Fatalf("\t%s\tShould report the validation error, got %v", failed, err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("\t%s\tShould report the invalid configuration.", failed)
+		}
+		if got := w.Value().Load(); got != (service{Level: "debug", Port: 9090}) {
+			t.Fatalf("\t%s\tShould keep the last valid configuration, got %+v", failed, got)
+		}
+		t.Logf("\t%s\tShould never apply the invalid configuration.", success)
+
+		if err := w.Reload(); err == nil {
+			t.Fatalf("\t%s\tShould return the error of the manual reload.", failed)
+		}
+		t.Logf("\t%s\tShould return the error of the manual reload.", success)
+	}
+
+	t.Logf("Given the need to test reloading the configuration from a subscriber")
+	{
+		os.Clearenv()
+		path := t.TempDir() + "/config.yaml"
+		write := func(data string) {
+			if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+				t.Fatalf("\t%s\tShould be able to write the config file: %v", failed, err)
+			}
+		}
+		write("level: info\n")
+
+		w, err := config.NewWatcher[service](config.WithArgs(nil), config.WithParsers(yaml.File(path)))
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create the watcher: %v", failed, err)
+		}
+
+		var (
+			got       []string
+			reloadErr error
+		)
+		w.Value().Subscribe(func(old, new service) {
+			got = append(got, old.Level+"->"+new.Level)
+			if new.Level == "debug" {
+				write("level: warn\n")
+				reloadErr = w.Reload()
+			}
+		})
+
+		write("level: debug\n")
+		done := make(chan error)
+		go func() {
+			done <- w.Reload()
+		}()
+
+		select {
+		case err := <-done:
+			if err != nil || reloadErr != nil {
+				t.Fatalf("\t%s\tShould be able to reload from a subscriber: %v, %v", failed, err, reloadErr)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("\t%s\tShould not deadlock when a subscriber reloads the configuration.", failed)
+		}
+		t.Logf("\t%s\tShould not deadlock when a subscriber reloads the configuration.", success)
+
+		want := []string{"info->debug", "debug->warn"}
+		if diff := cmp.Diff(want, got); diff != "" || w.Value().Load().Level != "warn" {
+			t.Fatalf("\t%s\tShould deliver the changes of the nested reload in order: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould deliver the changes of the nested reload in order.", success)
+	}
+}
+
+func TestValueSubscribers(t *testing.T) {
+	t.Logf("Given the need to test the subscribers changing the Value")
+	{
+		v := config.NewValue(0)
+
+		var (
+			got         []string
+			unsubscribe func()
+		)
+		unsubscribe = v.Subscribe(func(old, new int) {
+			got = append(got, fmt.Sprintf("once %d->%d", old, new))
+			unsubscribe()
+		})
+		v.Subscribe(func(old, new int) {
+			got = append(got, fmt.Sprintf("all %d->%d", old, new))
+			if new == 1 {
+				v.Store(2)
+				v.Subscribe(func(old, new int) {
+					got = append(got, fmt.Sprintf("late %d->%d", old, new))
+				})
+			}
+		})
+
+		done := make(chan struct{})
+		go func() {
+			v.Store(1)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("\t%s\tShould not deadlock when the subscribers change the Value.", failed)
+		}
+		t.Logf("\t%s\tShould not deadlock when the subscribers change the Value.", success)
+
+		want := []string{"once 0->1", "all 0->1", "all 1->2", "late 1->2"}
+		if diff := cmp.Diff(want, got); diff != "" || v.Load() != 2 {
+			t.Fatalf("\t%s\tShould deliver the changes in order: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould deliver the changes in order.", success)
+	}
+}
+
+func TestWatcherStatic(t *testing.T) {
+	type listener struct {
+		Host string `yaml:"host" default:"0.0.0.0"`
+		Port int    `yaml:"port" default:"8080"`
+	}
+
+	type service struct {
+		Listener listener `yaml:"listener" reload:"false"`
+		Level    string   `yaml:"level" default:"info"`
+		Token    string   `yaml:"token" mask:"true"`
+	}
+
+	t.Logf("Given the need to test the static fields on reload")
+	{
+		os.Clearenv()
+		path := t.TempDir() + "/config.yaml"
+		write := func(data string) {
+			if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+				t.Fatalf("\t%s\tShould be able to write the config file: %v", failed, err)
+			}
+		}
+		write("token: a\n")
+
+		w, err := config.NewWatcher[service](config.WithArgs(nil), config.WithParsers(yaml.File(path)))
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create the watcher: %v", failed, err)
+		}
+
+		var got config.Changes
+		w.Subscribe(func(old, new service, changes config.Changes) {
+			got = changes
+		})
+
+		write("listener:\n  port: 9090\nlevel: debug\ntoken: b\n")
+		err = w.Reload()
+
+		var restart *config.RestartError
+		if !errors.Is(err, config.ErrRestartRequired) || !errors.As(err, &restart) {
+			t.Fatalf("\t%s\tShould report the restart required, got %v", failed, err)
+		}
+		if err.Error() != "restart required: static fields changed: --listener-port" {
+			t.Fatalf("\t%s\tShould list the static fields, got %q", failed, err.Error())
+		}
+		t.Logf("\t%s\tShould report the restart required.", success)
+
+		want := service{Listener: listener{Host: "0.0.0.0", Port: 8080}, Level: "debug", Token: "b"}
+		if diff := cmp.Diff(want, w.Value().Load()); diff != "" {
+			t.Fatalf("\t%s\tShould apply the dynamic fields and keep the static ones: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould apply the dynamic fields and keep the static ones.", success)
+
+		wantChanges := config.Changes{
+			{Kind: config.Changed, Name: "Level", EnvVar: "LEVEL", Flag: "level", Old: "info", New: "debug"},
+			{Kind: config.Changed, Name: "Token", EnvVar: "TOKEN", Flag: "token", Old: "sha256:ca978112ca1b", New: "sha256:3e23e8160039"},
+		}
+		if diff := cmp.Diff(wantChanges, got); diff != "" {
+			t.Fatalf("\t%s\tShould pass the changes to the subscribers: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould pass the changes to the subscribers with hashed secrets.", success)
+	}
+
+	t.Logf("Given the need to test rejecting the static changes")
+	{
+		os.Clearenv()
+		path := t.TempDir() + "/config.yaml"
+		if err := os.WriteFile(path, []byte("level: info\n"), 0o600); err != nil {
+			t.Fatalf("\t%s\tShould be able to write the config file: %v", failed, err)
+		}
+
+		w, err := config.NewWatcher[service](config.WithArgs(nil), config.WithParsers(yaml.File(path)), config.WithRejectStaticChanges())
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create the watcher: %v", failed, err)
+		}
+
+		if err := os.WriteFile(path, []byte("listener:\n  host: 127.0.0.1\nlevel: warn\n"), 0o600); err != nil {
+			t.Fatalf("\t%s\tShould be able to write the config file: %v", failed, err)
+		}
+		if err := w.Reload(); !errors.Is(err, config.ErrRestartRequired) {
+			t.Fatalf("\t%s\tShould reject the reload, got %v", failed, err)
+		}
+		if got := w.Value().Load().Level; got != "info" {
+			t.Fatalf("\t%s\tShould not apply the rejected reload, got level %q", failed, got)
+		}
+		t.Logf("\t%s\tShould not apply the rejected reload.", success)
+	}
+
+	type tls struct {
+		Cert string `yaml:"cert"`
+	}
+
+	type upstream struct {
+		Host string `yaml:"host"`
+	}
+
+	type server struct {
+		TLS       *tls       `yaml:"tls" reload:"false"`
+		Upstreams []upstream `yaml:"upstreams" reload:"false"`
+		Level     string     `yaml:"level" default:"info"`
+	}
+
+	t.Logf("Given the need to test the static sections and collections added and removed on reload")
+	{
+		tests := []struct {
+			name   string
+			first  string
+			second string
+			want   server
+		}{
+			{
+				name:   "added",
+				first:  "upstreams:\n  - host: a\n",
+				second: "tls:\n  cert: c.pem\nupstreams:\n  - host: a\n  - host: b\nlevel: debug\n",
+				want:   server{Upstreams: []upstream{{Host: "a"}}, Level: "debug"},
+			},
+			{
+				name:   "removed",
+				first:  "tls:\n  cert: c.pem\nupstreams:\n  - host: a\n  - host: b\n",
+				second: "upstreams:\n  - host: a\nlevel: debug\n",
+				want:   server{TLS: &tls{Cert: "c.pem"}, Upstreams: []upstream{{Host: "a"}, {Host: "b"}}, Level: "debug"},
+			},
+		}
+
+		for _, tt := range tests {
+			os.Clearenv()
+			path := t.TempDir() + "/config.yaml"
+			if err := os.WriteFile(path, []byte(tt.first), 0o600); err != nil {
+				t.Fatalf("\t%s\tShould be able to write the config file: %v", failed, err)
+			}
+
+			w, err := config.NewWatcher[server](config.WithArgs(nil), config.WithParsers(yaml.File(path)))
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to create the watcher: %v", failed, err)
+			}
+
+			if err := os.WriteFile(path, []byte(tt.second), 0o600); err != nil {
+				t.Fatalf("\t%s\tShould be able to write the config file: %v", failed, err)
+			}
+			if err := w.Reload(); !errors.Is(err, config.ErrRestartRequired) {
+				t.Fatalf("\t%s\tShould report the restart required for the %s static fields, got %v", failed, tt.name, err)
+			}
+
+			if diff := cmp.Diff(tt.want, w.Value().Load()); diff != "" {
+				t.Fatalf("\t%s\tShould keep the %s static section and elements: %s", failed, tt.name, diff)
+			}
+			t.Logf("\t%s\tShould keep the %s static section and elements.", success, tt.name)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	type backend struct {
+		URL string `yaml:"url"`
+	}
+
+	type app struct {
+		Level    string             `env:"LEVEL"`
+		Password string             `env:"PASSWORD"`
+		Backends map[string]backend `env:"BACKENDS"`
+	}
+
+	before := app{
+		Level:    "info",
+		Password: "a",
+		Backends: map[string]backend{"old": {URL: "http://old"}, "same": {URL: "http://same"}},
+	}
+	after := app{
+		Level:    "debug",
+		Password: "b",
+		Backends: map[string]backend{"new": {URL: "http://new"}, "same": {URL: "http://same"}},
+	}
+
+	t.Logf("Given the need to test the diff of two configurations")
+	{
+		changes, err := config.Diff(&before, &after)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to diff the configurations: %v", failed, err)
+		}
+
+		wantText := "~ --level ($LEVEL): info -> debug\n" +
+			"~ --password ($PASSWORD): sha256:ca978112ca1b -> sha256:3e23e8160039\n" +
+			"+ --backends.new.url ($BACKENDS_NEW_URL): http://new\n" +
+			"- --backends.old.url ($BACKENDS_OLD_URL): http://old\n"
+		if diff := cmp.Diff(wantText, changes.Text()); diff != "" {
+			t.Fatalf("\t%s\tShould render the text diff: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould render the text diff with hashed secrets.", success)
+
+		wantUnified := "--- old\n+++ new\n" +
+			"-LEVEL=info\n+LEVEL=debug\n" +
+			"-PASSWORD=sha256:ca978112ca1b\n+PASSWORD=sha256:3e23e8160039\n" +
+			"+BACKENDS_NEW_URL=http://new\n" +
+			"-BACKENDS_OLD_URL=http://old\n"
+		if diff := cmp.Diff(wantUnified, changes.Unified()); diff != "" {
+			t.Fatalf("\t%s\tShould render the unified diff: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould render the unified diff.", success)
+
+		js, err := changes.Added().JSON()
+		want := `[{"kind":"added","name":"Backends_new_URL","env":"BACKENDS_NEW_URL","flag":"backends.new.url","new":"http://new"}]`
+		if err != nil || js != want {
+			t.Fatalf("\t%s\tShould render the JSON diff, got %s, %v", failed, js, err)
+		}
+		t.Logf("\t%s\tShould render the JSON diff.", success)
+
+		if len(changes.Removed()) != 1 || len(changes.Changed()) != 2 {
+			t.Fatalf("\t%s\tShould filter the changes by kind, got %v", failed, changes)
+		}
+		t.Logf("\t%s\tShould filter the changes by kind.", success)
+	}
+
+	t.Logf("Given the need to test the diff with the settings of the Loader")
+	{
+		type point struct {
+			X, Y int
+		}
+
+		type service struct {
+			Hosts  []string `env:"HOSTS"`
+			Origin point    `env:"ORIGIN"`
+		}
+
+		parsePoint := func(s string) (point, error) {
+			var p point
+			_, err := fmt.Sscanf(s, "%d:%d", &p.X, &p.Y)
+			return p, err
+		}
+
+		os.Clearenv()
+		os.Setenv("HOSTS", "a,b")
+		os.Setenv("ORIGIN", "1:2")
+
+		w, err := config.NewWatcher[service](
+			config.WithArgs(nil),
+			config.WithDelimiter(";"),
+			config.WithType(parsePoint),
+		)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create the watcher: %v", failed, err)
+		}
+
+		var got config.Changes
+		w.Subscribe(func(old, new service, changes config.Changes) {
+			got = changes
+		})
+
+		os.Setenv("HOSTS", "a;b")
+		os.Setenv("ORIGIN", "3:4")
+		if err := w.Reload(); err != nil {
+			t.Fatalf("\t%s\tShould be able to reload the configuration: %v", failed, err)
+		}
+
+		want := "~ --hosts ($HOSTS): a,b -> a;b\n" +
+			"~ --origin ($ORIGIN): {X:1 Y:2} -> {X:3 Y:4}\n"
+		if diff := cmp.Diff(want, got.Text()); diff != "" {
+			t.Fatalf("\t%s\tShould report the changes with the delimiter and the structs without a text form: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould report the changes with the delimiter and the structs without a text form.", success)
+	}
+}
+
+func TestDocs(t *testing.T) {
+	type tls struct {
+		Cert string `usage:"certificate file"`
+	}
+
+	type server struct {
+		Host string `default:"localhost" usage:"host|address"`
+		TLS  tls
+	}
+
+	type app struct {
+		Port   int    `shortFlag:"p" min:"1" max:"65535" required:"true" usage:"port to listen on"`
+		Level  string `oneof:"debug info" deprecated:"use --log-level"`
+		Server server
+	}
+
+	var cfg app
+
+	t.Logf("Given the need to test the generated Markdown reference")
+	{
+		md, err := config.Markdown(&cfg)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to generate the Markdown: %v", failed, err)
+		}
+
+		header := "| Environment | Flag | Short | Type | Default | Required | Validation | Description |\n" +
+			"|-------------|------|-------|------|---------|----------|------------|-------------|\n"
+		want := "# config.test configuration\n\n" + header +
+			"| `PORT` | `--port` | `-p` | int |  | yes | min: 1; max: 65535 | port to listen on |\n" +
+			"| `LEVEL` | `--level` |  | string |  |  | one of: debug, info | **Deprecated: use --log-level.** |\n" +
+			"\n## Server\n\n" + header +
+			"| `SERVER_HOST` | `--server-host` |  | string | `localhost` |  |  | host\\|address |\n" +
+			"\n## Server.TLS\n\n" + header +
+			"| `SERVER_TLS_CERT` | `--server-tls-cert` |  | string |  |  |  | certificate file |\n"
+		if diff := cmp.Diff(want, md); diff != "" {
+			t.Fatalf("\t%s\tShould list the nested structs in sections: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould list the nested structs in sections.", success)
+	}
+
+	t.Logf("Given the need to test the generated man page")
+	{
+		man, err := config.ManPage(&cfg, "1.2.3")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to generate the man page: %v", failed, err)
+		}
+
+		for _, want := range []string{
+			".TH \"CONFIG.TEST\" 1 \"\" \"config.test 1.2.3\" \"User Commands\"\n",
+			".TP\n\\fB\\-p\\fR, \\fB\\-\\-port\\fR \\fIint\\fR\nport to listen on\n.br\n" +
+				"Environment: \\fBPORT\\fR. Required. Validation: min: 1; max: 65535.\n",
+			"Deprecated: use \\-\\-log\\-level.\n",
+			".SS Server.TLS\n.TP\n\\fB\\-\\-server\\-tls\\-cert\\fR \\fIstring\\fR\n",
+		} {
+			if !strings.Contains(man, want) {
+				t.Fatalf("\t%s\tShould contain %q, got:\n%s", failed, want, man)
+			}
+		}
+		t.Logf("\t%s\tShould render the options in roff.", success)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	type tls struct {
+		Cert string `yaml:"cert" required:"true" usage:"certificate file"`
+	}
+
+	type upstream struct {
+		Host   string `yaml:"host"`
+		Weight int    `yaml:"weight" default:"1"`
+	}
+
+	type app struct {
+		Port      int            `yaml:"port" default:"8080" min:"1" max:"65535" usage:"port to listen on"`
+		Level     string         `json:"level" oneof:"debug info" deprecated:"true"`
+		Tags      []string       `yaml:"tags" oneof:"a b" max:"3"`
+		Timeout   time.Duration  `yaml:"timeout" default:"1m"`
+		Upstreams []upstream     `yaml:"upstreams"`
+		TLS       *tls           `yaml:"tls"`
+		Zone      *time.Location `yaml:"zone" default:"UTC"`
+		Internal  string         `yaml:"-"`
+	}
+
+	var cfg app
+
+	t.Logf("Given the need to test the JSON Schema of the configuration")
+	{
+		s, err := config.JSONSchema(&cfg)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to generate the schema: %v", failed, err)
+		}
+
+		var got map[string]interface{}
+		if err := json.Unmarshal([]byte(s), &got); err != nil {
+			t.Fatalf("\t%s\tShould generate valid JSON: %v", failed, err)
+		}
+
+		want := map[string]interface{}{
+			"$schema": "https://json-schema.org/draft/2020-12/schema",
+			"title":   "config.test",
+			"type":    "object",
+			"properties": map[string]interface{}{
+				"port": map[string]interface{}{
+					"type": "integer", "default": 8080.0, "minimum": 1.0, "maximum": 65535.0,
+					"description": "port to listen on",
+				},
+				"level": map[string]interface{}{
+					"type": "string", "enum": []interface{}{"debug", "info"}, "deprecated": true,
+				},
+				"tags": map[string]interface{}{
+					"type": "array", "maxItems": 3.0,
+					"items": map[string]interface{}{"type": "string", "enum": []interface{}{"a", "b"}},
+				},
+				"timeout": map[string]interface{}{"type": "string", "default": "1m0s"},
+				"upstreams": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"host":   map[string]interface{}{"type": "string"},
+							"weight": map[string]interface{}{"type": "integer", "default": 1.0},
+						},
+					},
+				},
+				"tls": map[string]interface{}{
+					"type":     "object",
+					"required": []interface{}{"cert"},
+					"properties": map[string]interface{}{
+						"cert": map[string]interface{}{"type": "string", "description": "certificate file"},
+					},
+				},
+				"zone": map[string]interface{}{"type": "string", "default": "UTC"},
+			},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("\t%s\tShould describe the fields: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould describe the types, defaults and validation rules of the fields.", success)
+	}
+}
+
+func TestSample(t *testing.T) {
+	type tls struct {
+		Cert string `yaml:"cert" required:"true" usage:"certificate file"`
+	}
+
+	type app struct {
+		Port     int           `yaml:"port" default:"8080" min:"1" usage:"port to listen on"`
+		Level    string        `yaml:"level" default:"info" oneof:"debug info"`
+		Tags     []string      `yaml:"tags" default:"a,b"`
+		Timeout  time.Duration `yaml:"timeout" default:"1m"`
+		Password string        `yaml:"password"`
+		Pin      int           `yaml:"pin" mask:"true" default:"1234"`
+		Started  time.Time     `yaml:"started"`
+		TLS      tls           `yaml:"tls"`
+	}
+
+	os.Clearenv()
+
+	t.Logf("Given the need to test the sample configuration files")
+	{
+		var cfg app
+		got, err := config.Sample(&cfg, "yaml")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to generate the yaml sample: %v", failed, err)
+		}
+
+		want := "# port to listen on (min: 1)\n" +
+			"port: 8080\n" +
+			"# one of: debug, info\n" +
+			"level: \"info\"\n" +
+			"tags: [\"a\",\"b\"]\n" +
+			"timeout: \"1m0s\"\n" +
+			"password: \"<secret>\"\n" +
+			"# pin: 1234\n" +
+			"# started: \"\"\n" +
+			"tls:\n" +
+			"  # certificate file (required)\n" +
+			"  cert: \"\"\n"
+		_, body, _ := strings.Cut(got, "\n")
+		if diff := cmp.Diff(want, body); diff != "" {
+			t.Fatalf("\t%s\tShould comment the fields and fill in the defaults: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould comment the fields and fill in the defaults.", success)
+
+		var loaded app
+		if err := config.ProcessWithParser(&loaded, []config.Parser{yaml.WithData([]byte(got))}); err == nil || !strings.Contains(err.Error(), "TLS_Cert") {
+			t.Fatalf("\t%s\tShould load the sample and require the marked fields, got %v", failed, err)
+		}
+		if loaded.Port != 8080 || loaded.Timeout != time.Minute || loaded.Password != "<secret>" || loaded.Pin != 1234 {
+			t.Fatalf("\t%s\tShould load the sample, got %+v", failed, loaded)
+		}
+		t.Logf("\t%s\tShould load the yaml sample back.", success)
+
+		toml, err := config.Sample(&cfg, "toml")
+		if err != nil || !strings.Contains(toml, "tags = [\"a\", \"b\"]\n") || !strings.Contains(toml, "\n[tls]\n# certificate file (required)\ncert = \"\"\n") {
+			t.Fatalf("\t%s\tShould generate the toml sample, got %s, %v", failed, toml, err)
+		}
+		t.Logf("\t%s\tShould generate the toml sample.", success)
+
+		js, err := config.Sample(&cfg, "json")
+		var obj map[string]interface{}
+		if err != nil || json.Unmarshal([]byte(js), &obj) != nil || obj["port"] != 8080.0 {
+			t.Fatalf("\t%s\tShould generate the json sample, got %s, %v", failed, js, err)
+		}
+		t.Logf("\t%s\tShould generate the json sample.", success)
+
+		var out strings.Builder
+		l := config.NewLoader(config.WithArgs([]string{"--print-sample-config=env"}), config.WithOutput(&out))
+		if err := l.Load(&cfg); !errors.Is(err, config.ErrSampleConfig) {
+			t.Fatalf("\t%s\tShould return ErrSampleConfig, got %v", failed, err)
+		}
+		if !strings.Contains(out.String(), "\nPORT=8080\n") || !strings.Contains(out.String(), "\nPASSWORD=<secret>\n") ||
+			!strings.Contains(out.String(), "\n# PIN=1234\n") || !strings.Contains(out.String(), "\n# STARTED=\n") {
+			t.Fatalf("\t%s\tShould print the env sample, got %s", failed, out.String())
+		}
+		t.Logf("\t%s\tShould print the env sample with the built-in flag.", success)
+
+		if _, err := config.Sample(&cfg, "ini"); err == nil {
+			t.Fatalf("\t%s\tShould reject the unsupported format.", failed)
+		}
+		t.Logf("\t%s\tShould reject the unsupported format.", success)
+	}
+
+	type secrets struct {
+		Key      string         `yaml:"key" mask:"true" default:"abc"`
+		Pin      int            `yaml:"pin" mask:"true"`
+		TTL      time.Duration  `yaml:"ttl" mask:"hash" default:"1h"`
+		Started  time.Time      `yaml:"started"`
+		Stopped  *time.Time     `yaml:"stopped"`
+		Mode     os.FileMode    `yaml:"mode" default:"0644"`
+		Endpoint url.URL        `yaml:"endpoint" default:"https://x.io/a"`
+		Subnet   net.IPNet      `yaml:"subnet"`
+		Zone     *time.Location `yaml:"zone" default:"UTC"`
+		Level    slog.Level     `yaml:"level" default:"warn"`
+		Mirrors  []url.URL      `yaml:"mirrors" default:"https://a.io,https://b.io"`
+		Ratio    complex128     `yaml:"ratio" default:"1+2i"`
+		Raw      []byte         `yaml:"raw" default:"abc"`
+	}
+
+	location := cmp.Comparer(func(a, b *time.Location) bool {
+		return a == b || (a != nil && b != nil && a.String() == b.String())
+	})
+
+	t.Logf("Given the need to load the samples back")
+	{
+		os.Clearenv()
+
+		var want secrets
+		if err := config.NewLoader(config.WithArgs(nil)).Load(&want); err != nil {
+			t.Fatalf("\t%s\tShould be able to load the defaults: %v", failed, err)
+		}
+
+		for _, format := range []string{"yaml", "json"} {
+			var cfg secrets
+			got, err := config.Sample(&cfg, format)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to generate the %s sample: %v", failed, format, err)
+			}
+
+			path := t.TempDir() + "/config." + format
+			if err := os.WriteFile(path, []byte(got), 0o600); err != nil {
+				t.Fatalf("\t%s\tShould be able to write the %s sample: %v", failed, format, err)
+			}
+
+			var loaded secrets
+			if err := config.NewLoader(config.WithArgs(nil), config.WithParsers(yaml.File(path))).Load(&loaded); err != nil {
+				t.Fatalf("\t%s\tShould load the %s sample back, got %v:\n%s", failed, format, err, got)
+			}
+			if diff := cmp.Diff(want, loaded, location); diff != "" {
+				t.Fatalf("\t%s\tShould load the %s sample back with the defaults: %s", failed, format, diff)
+			}
+			t.Logf("\t%s\tShould load the %s sample back with the defaults.", success, format)
+		}
+
+		var cfg secrets
+		env, err := config.Sample(&cfg, "env")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to generate the env sample: %v", failed, err)
+		}
+		for _, line := range strings.Split(env, "\n") {
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			name, value, _ := strings.Cut(line, "=")
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			}
+			os.Setenv(name, value)
+		}
+
+		var loaded secrets
+		if err := config.NewLoader(config.WithArgs(nil)).Load(&loaded); err != nil {
+			t.Fatalf("\t%s\tShould load the env sample back, got %v:\n%s", failed, err, env)
+		}
+		if diff := cmp.Diff(want, loaded, location); diff != "" {
+			t.Fatalf("\t%s\tShould load the env sample back with the defaults: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould load the env sample back with the defaults.", success)
+
+		os.Clearenv()
+	}
+}
+
+func TestExport(t *testing.T) {
+	type upstream struct {
+		Host   string `yaml:"host"`
+		Weight int    `yaml:"weight"`
+	}
+
+	type tls struct {
+		Cert string `yaml:"cert"`
+	}
+
+	type options struct {
+		MaxRetries int    `yaml:"max_retries"`
+		Mode       string `yaml:"mode"`
+	}
+
+	type Auth struct {
+		APIKey string `yaml:"api_key"`
+	}
+
+	type Region struct {
+		Zone string `yaml:"zone"`
+	}
+
+	type app struct {
+		Auth
+		Region    `yaml:",inline"`
+		Port      int                 `yaml:"port" default:"8080"`
+		Debug     bool                `yaml:"debug"`
+		Ratio     float64             `yaml:"ratio"`
+		Timeout   time.Duration       `yaml:"timeout"`
+		Tags      []string            `yaml:"tags"`
+		Limits    map[string]int      `yaml:"limits"`
+		Password  string              `yaml:"password"`
+		Upstreams []upstream          `yaml:"upstreams"`
+		Backends  map[string]upstream `yaml:"backends"`
+		TLS       *tls                `yaml:"tls"`
+		Unset     *tls                `yaml:"unset"`
+		Opts      options             `yaml:"opts" format:"json"`
+		Mode      os.FileMode         `yaml:"mode"`
+		Endpoint  url.URL             `yaml:"endpoint"`
+		Subnet    net.IPNet           `yaml:"subnet"`
+		Level     slog.Level          `yaml:"level"`
+	}
+
+	cfg := app{
+		Port:      9090,
+		Debug:     true,
+		Ratio:     0.5,
+		Timeout:   90 * time.Second,
+		Tags:      []string{"a", "b c"},
+		Limits:    map[string]int{"x": 1, "y": 2},
+		Password:  "s3cret",
+		Upstreams: []upstream{{Host: "a", Weight: 1}, {Host: "b", Weight: 2}},
+		Backends:  map[string]upstream{"primary": {Host: "p", Weight: 3}},
+		TLS:       &tls{Cert: "/etc/cert.pem"},
+		Opts:      options{MaxRetries: 3, Mode: "fast"},
+		Auth:      Auth{APIKey: "k"},
+		Region:    Region{Zone: "eu"},
+		Mode:      0o644,
+		Endpoint:  url.URL{Scheme: "https", Host: "x.io", Path: "/a"},
+		Subnet:    net.IPNet{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)},
+		Level:     slog.LevelWarn,
+	}
+
+	os.Clearenv()
+
+	t.Logf("Given the need to test the export of the effective configuration")
+	{
+		got, err := config.Export(&cfg, "yaml")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to export yaml: %v", failed, err)
+		}
+
+		want := "auth:\n" +
+			"  api_key: \"k\"\n" +
+			"zone: \"eu\"\n" +
+			"port: 9090\n" +
+			"debug: true\n" +
+			"ratio: 0.5\n" +
+			"timeout: \"1m30s\"\n" +
+			"tags: [\"a\",\"b c\"]\n" +
+			"limits: {\"x\":1,\"y\":2}\n" +
+			"password: \"********\"\n" +
+			"upstreams:\n" +
+			"  - host: \"a\"\n" +
+			"    weight: 1\n" +
+			"  - host: \"b\"\n" +
+			"    weight: 2\n" +
+			"backends:\n" +
+			"  primary:\n" +
+			"    host: \"p\"\n" +
+			"    weight: 3\n" +
+			"tls:\n" +
+			"  cert: \"/etc/cert.pem\"\n" +
+			"opts:\n" +
+			"  max_retries: 3\n" +
+			"  mode: \"fast\"\n" +
+			"mode: \"0644\"\n" +
+			"endpoint: \"https://x.io/a\"\n" +
+			"subnet: \"10.0.0.0/8\"\n" +
+			"level: \"WARN\"\n"
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("\t%s\tShould export the nested yaml with the masked secrets: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould export the nested yaml with the masked secrets.", success)
+
+		for _, format := range []string{"yaml", "json"} {
+			out, err := config.Export(&cfg, format, config.WithExportMask(config.MaskNone))
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to export %s: %v", failed, format, err)
+			}
+
+			var loaded app
+			l := config.NewLoader(config.WithArgs([]string{}), config.WithParsers(yaml.WithData([]byte(out))))
+			if err := l.Load(&loaded); err != nil {
+				t.Fatalf("\t%s\tShould load the exported %s: %v", failed, format, err)
+			}
+			if diff := cmp.Diff(cfg, loaded); diff != "" {
+				t.Fatalf("\t%s\tShould round-trip the %s export: %s", failed, format, diff)
+			}
+			t.Logf("\t%s\tShould round-trip the %s export.", success, format)
+		}
+
+		env, err := config.Export(&cfg, "env", config.WithExportMask(config.MaskNone))
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to export env: %v", failed, err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(env), "\n") {
+			name, value, _ := strings.Cut(line, "=")
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			}
+			os.Setenv(name, value)
+		}
+
+		var fromEnv app
+		if err := config.NewLoader(config.WithArgs([]string{})).Load(&fromEnv); err != nil {
+			t.Fatalf("\t%s\tShould load the exported env: %v", failed, err)
+		}
+		if diff := cmp.Diff(cfg, fromEnv); diff != "" {
+			t.Fatalf("\t%s\tShould round-trip the env export: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould round-trip the env export.", success)
+
+		os.Clearenv()
+
+		args, err := config.ExportArgs(&cfg, config.WithExportMask(config.MaskNone))
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to export args: %v", failed, err)
+		}
+
+		var fromArgs app
+		if err := config.NewLoader(config.WithArgs(args)).Load(&fromArgs); err != nil {
+			t.Fatalf("\t%s\tShould load the exported args %v: %v", failed, args, err)
+		}
+		if diff := cmp.Diff(cfg, fromArgs); diff != "" {
+			t.Fatalf("\t%s\tShould round-trip the args export: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould round-trip the args export.", success)
+
+		line, err := config.Export(&cfg, "args")
+		if err != nil || !strings.Contains(line, "'--tags=a,b c'") || !strings.Contains(line, "--password=********") {
+			t.Fatalf("\t%s\tShould quote the args for the shell, got %s, %v", failed, line, err)
+		}
+		t.Logf("\t%s\tShould quote the args for the shell.", success)
+	}
+}
+
+func TestKubernetes(t *testing.T) {
+	type app struct {
+		Port     int      `default:"8080" usage:"port to listen on"`
+		Host     string   `required:"true"`
+		Tags     []string `default:"a,b"`
+		Debug    bool
+		Password string `required:"true" usage:"database password"`
+		APIToken string `env:"API_TOKEN"`
+	}
+
+	os.Clearenv()
+	os.Setenv("PASSWORD", "s3cret")
+	defer os.Clearenv()
+
+	var cfg app
+	if err := config.NewLoader(config.WithArgs([]string{"--host=db"})).Load(&cfg); err != nil {
+		t.Fatalf("\t%s\tShould be able to load the configuration: %v", failed, err)
+	}
+	cfg.Host = ""
+
+	t.Logf("Given the need to test the Kubernetes manifests")
+	{
+		got, err := config.KubernetesManifests(&cfg, "app")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to generate the manifests: %v", failed, err)
+		}
+
+		want := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\ndata:\n" +
+			"  # port to listen on\n" +
+			"  PORT: \"8080\"\n" +
+			"  # required\n" +
+			"  # HOST: \"\"\n" +
+			"  TAGS: \"a,b\"\n" +
+			"  DEBUG: \"false\"\n" +
+			"---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: app\ntype: Opaque\nstringData:\n" +
+			"  # database password (required)\n" +
+			"  # PASSWORD: \"\"\n" +
+			"  # API_TOKEN: \"\"\n"
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("\t%s\tShould split the fields into the ConfigMap and the Secret: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould split the fields into the ConfigMap and the Secret without the secret values.", success)
+
+		env, err := config.KubernetesEnv(&cfg, "app")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to generate the env block: %v", failed, err)
+		}
+
+		wantEnv := "envFrom:\n  - configMapRef:\n      name: app\n" +
+			"env:\n" +
+			"  # required\n" +
+			"  - name: HOST\n    valueFrom:\n      configMapKeyRef:\n        name: app\n        key: HOST\n" +
+			"  # required\n" +
+			"  - name: PASSWORD\n    valueFrom:\n      secretKeyRef:\n        name: app\n        key: PASSWORD\n" +
+			"  - name: API_TOKEN\n    valueFrom:\n      secretKeyRef:\n        name: app\n        key: API_TOKEN\n        optional: true\n"
+		if diff := cmp.Diff(wantEnv, env); diff != "" {
+			t.Fatalf("\t%s\tShould reference the ConfigMap and the secrets: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould reference the ConfigMap and the secrets with the required keys flagged.", success)
+	}
+}
+
+func TestUsageOptions(t *testing.T) {
+	type tls struct {
+		Cert string `usage:"certificate file"`
+	}
+
+	type app struct {
+		Port     int    `shortFlag:"p" default:"8080" usage:"port to listen on, the service accepts the connections of the clients on it"`
+		Internal string `hidden:"true"`
+		TLS      tls
+	}
+
+	os.Clearenv()
+
+	t.Logf("Given the need to test the options of the usage message")
+	{
+		var cfg app
+		var out strings.Builder
+		err := config.PrintUsage(&cfg,
+			config.WithOutput(&out),
+			config.WithProgramName("app"),
+			config.WithDescription("App serves the requests."),
+			config.WithUsageHeader("App v1.0"),
+			config.WithUsageFooter("Docs: https://example.com/app"),
+			config.WithExamples("app --port 9090"),
+			config.WithWrapWidth(60),
+		)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to print the usage message: %v", failed, err)
+		}
+
+		want := "App v1.0\n\n" +
+			"Usage: app [options] [arguments]\n\n" +
+			"App serves the requests.\n\n" +
+			"Options:\n" +
+			"-p,  --port | $PORT int  port to listen on, the service\n" +
+			"                         accepts the connections of the\n" +
+			"                         clients on it (default: 8080)\n\n" +
+			"TLS Options:\n" +
+			"     --tls-cert | $TLS_CERT string  certificate file \n\n" +
+			"Global Options:\n" +
+			"-h,  --help     show this help message\n" +
+			"-v,  --version  show version\n\n" +
+			"Examples:\n" +
+			"  app --port 9090\n\n" +
+			"Docs: https://example.com/app\n"
+		if diff := cmp.Diff(want, out.String()); diff != "" {
+			t.Fatalf("\t%s\tShould customize, group and wrap the usage message: %s", failed, diff)
+		}
+		t.Logf("\t%s\tShould customize, group and wrap the usage message without the hidden fields.", success)
+
+		usage, err := config.UsageMessage(&cfg, config.WithProgramName("app"),
+			config.WithUsageTemplate(`{{.AppName}}:{{range .Groups}} [{{.Name}}]{{range .Fields}} {{.Flag}}{{end}}{{end}}`))
+		if err != nil || usage != "app: [] port [TLS] tls-cert" {
+			t.Fatalf("\t%s\tShould execute the custom template, got %q, %v", failed, usage, err)
+		}
+		t.Logf("\t%s\tShould execute the custom template.", success)
+
+		if _, err := config.UsageMessage(&cfg, config.WithUsageTemplate("{{.Missing")); err == nil {
+			t.Fatalf("\t%s\tShould return the error of the invalid template.", failed)
+		}
+		t.Logf("\t%s\tShould return the error of the invalid template.", success)
+	}
+}
@@ -0,0 +1,225 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ChangeKind is the kind of the change of a field.
+type ChangeKind string
+
+// The kinds of the changes.
+const (
+	// Added is a field which is only in the new configuration, for example a new map element.
+	Added ChangeKind = "added"
+	// Removed is a field which is only in the old configuration.
+	Removed ChangeKind = "removed"
+	// Changed is a field with different values in the configurations.
+	Changed ChangeKind = "changed"
+)
+
+// Change is the change of a field between two configurations. The values of the masked fields
+// are hashed, so the change is visible without revealing the values.
+type Change struct {
+	Kind   ChangeKind `json:"kind"`
+	Name   string     `json:"name"`
+	EnvVar string     `json:"env"`
+	Flag   string     `json:"flag"`
+	Old    string     `json:"old,omitempty"`
+	New    string     `json:"new,omitempty"`
+
+	// Static reports whether the field is tagged with reload:"false".
+	Static bool `json:"static,omitempty"`
+}
+
+// Changes are the changes between two configurations returned by Diff.
+type Changes []Change
+
+// Diff compares two configurations, pointers to structs of the same type, and returns the added,
+// removed and changed fields in the order of the fields. It can be used to log the reloads or to
+// compare the effective configuration of two environments.
+//
+//	changes, err := config.Diff(&staging, &production)
+//	fmt.Print(changes.Text())
+func Diff(old, new interface{}) (Changes, error) {
+	return NewLoader().diff(old, new)
+}
+
+// diff compares the configurations with the settings of the Loader.
+func (l *Loader) diff(old, new interface{}) (Changes, error) {
+	oldFields, _, err := l.currentFields(old)
+	if err != nil {
+		return nil, err
+	}
+	newFields, _, err := l.currentFields(new)
+	if err != nil {
+		return nil, err
+	}
+
+	oldValues := make(map[string]Field, len(oldFields))
+	for _, f := range oldFields {
+		oldValues[f.Flag] = f
+	}
+
+	var changes Changes
+	seen := make(map[string]bool, len(newFields))
+	for _, f := range newFields {
+		seen[f.Flag] = true
+		newValue := valueToString(f.FieldValue, l.options(f))
+
+		o, ok := oldValues[f.Flag]
+		if !ok {
+			changes = append(changes, newChange(Added, f, "", newValue))
+			continue
+		}
+
+		oldValue := valueToString(o.FieldValue, l.options(o))
+		switch {
+		case oldValue != newValue:
+			changes = append(changes, newChange(Changed, f, oldValue, newValue))
+		case l.textless(o) && !reflect.DeepEqual(o.FieldValue.Interface(), f.FieldValue.Interface()):
+			changes = append(changes, newChange(Changed, f, fmt.Sprintf("%+v", o.FieldValue.Interface()),
+				fmt.Sprintf("%+v", f.FieldValue.Interface())))
+		}
+	}
+
+	for _, f := range oldFields {
+		if !seen[f.Flag] {
+			changes = append(changes, newChange(Removed, f, valueToString(f.FieldValue, l.options(f)), ""))
+		}
+	}
+
+	return changes, nil
+}
+
+// textless reports whether the value of the field is a struct without a text form, like a type
+// registered with WithType without a formatter, which valueToString renders as an empty string.
+// Such values are compared with reflect.DeepEqual.
+func (l *Loader) textless(f Field) bool {
+	opts := l.options(f)
+	if opts.format == formatJSON {
+		return false
+	}
+
+	v := f.FieldValue
+	for v.Kind() == reflect.Ptr && !v.IsNil() && valueType(v.Type()) != v.Type() {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct || !isLeaf(l.types, v.Type()) || !v.CanInterface() {
+		return false
+	}
+
+	if _, ok := lookupFormatter(v.Type()); ok {
+		return false
+	}
+	if _, ok := formatBuiltin(v, opts); ok {
+		return false
+	}
+	_, ok := formatValue(v)
+
+	return !ok
+}
+
+// newChange returns the change of the field, the values of the masked fields are hashed.
+func newChange(kind ChangeKind, f Field, oldValue, newValue string) Change {
+	if f.MaskMode != MaskNone {
+		oldValue, newValue = maskString(oldValue, MaskHash), maskString(newValue, MaskHash)
+	}
+
+	return Change{
+		Kind:   kind,
+		Name:   f.Name,
+		EnvVar: f.EnvVar,
+		Flag:   f.Flag,
+		Old:    oldValue,
+		New:    newValue,
+		Static: f.Static,
+	}
+}
+
+// Added returns the added fields.
+func (c Changes) Added() Changes {
+	return c.filter(Added)
+}
+
+// Removed returns the removed fields.
+func (c Changes) Removed() Changes {
+	return c.filter(Removed)
+}
+
+// Changed returns the fields with changed values.
+func (c Changes) Changed() Changes {
+	return c.filter(Changed)
+}
+
+// filter returns the changes of the kind.
+func (c Changes) filter(kind ChangeKind) Changes {
+	var changes Changes
+	for _, change := range c {
+		if change.Kind == kind {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes
+}
+
+// Text renders the changes one per line, prefixed with + for the added, - for the removed
+// and ~ for the changed fields:
+//
+//	~ --level ($LEVEL): info -> debug
+//	+ --upstreams.2.host ($UPSTREAMS_2_HOST): c.local
+func (c Changes) Text() string {
+	var sb strings.Builder
+	for _, change := range c {
+		name := fmt.Sprintf("--%s ($%s)", change.Flag, change.EnvVar)
+		switch change.Kind {
+		case Added:
+			fmt.Fprintf(&sb, "+ %s: %s\n", name, change.New)
+		case Removed:
+			fmt.Fprintf(&sb, "- %s: %s\n", name, change.Old)
+		default:
+			fmt.Fprintf(&sb, "~ %s: %s -> %s\n", name, change.Old, change.New)
+		}
+	}
+
+	return sb.String()
+}
+
+// Unified renders the changes in the unified diff format, the changed fields are removed
+// with their old value and added with the new one.
+func (c Changes) Unified() string {
+	if len(c) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("--- old\n+++ new\n")
+	for _, change := range c {
+		if change.Kind != Added {
+			fmt.Fprintf(&sb, "-%s=%s\n", change.EnvVar, change.Old)
+		}
+		if change.Kind != Removed {
+			fmt.Fprintf(&sb, "+%s=%s\n", change.EnvVar, change.New)
+		}
+	}
+
+	return sb.String()
+}
+
+// JSON renders the changes as a JSON array.
+func (c Changes) JSON() (string, error) {
+	if c == nil {
+		c = Changes{}
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
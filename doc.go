@@ -18,6 +18,26 @@ Usage:
 	   - flag: Specifies the command line flag name for the field.
 	   - shortFlag: Specifies the short command line flag name for the field.
 	   - mask: Specifies whether the field value should be masked in the output.
+	     Accepts true, false or one of the modes: full, last4, first2, hash, length. The string and
+	     byte slice fields whose environment variable ends with PASSWORD, TOKEN, SECRET, ... are masked
+	     automatically, WithMaskPatterns replaces the patterns.
+	   - args: Collects the positional command line arguments into a []string field when set to true.
+	   - delim: Specifies the delimiter used to split slice and map values. Default is ",".
+	   - subdelim: Specifies the delimiter of the nested collections, for example a;b,c;d for [][]string.
+	     Default is ";", or "," when the delimiter is ";".
+	   - reload: Set to false for the static fields which can't change at runtime, the Watcher keeps their values.
+	   - kvsep: Specifies the separator of map keys and values. Default is ":", which accepts "=" too.
+	   - deprecated: Marks the field as deprecated in the generated documentation, true or the deprecation message.
+	   - hidden: Set to true to keep an internal field out of the usage message and the shell completion.
+	   - oneof: Specifies the space separated list of the allowed values, for example oneof:"debug info warn".
+	   - complete: Specifies the shell completion of the value: file or dir.
+	   - layout: Specifies the layout of a time.Time value, default is time.RFC3339.
+	   - min, max: Specify the bounds of the value in the units of the field type, for example min:"1KiB" for ByteSize.
+	     Strings are bounded by length, slices and maps by the number of elements. An explicit zero value
+	     is validated too, the zero value of an unset field is not.
+	   - format: Set to json to decode the value as JSON. Slices, arrays and maps are also decoded as JSON
+	     when the value starts with [ or {, for example ROUTES='[{"path":"/a","weight":3}]', except the
+	     values starting with a bracketed IPv6 address like [::1]:7000.
 
 	 Defining Configuration Struct:
 
@@ -29,6 +49,15 @@ Usage:
 		    // ... other configuration fields
 		}
 
+	 Command Line Flags:
+
+	 Flags follow the POSIX/GNU conventions: --flag value, --flag=value, -f value, -fvalue and bundled
+	 short booleans like -abc. Arguments after "--" and the arguments that are not flags are positional.
+	 Repeated flags of slice and map fields accumulate, --tag a --tag b is the same as --tag a,b.
+	 Boolean flags can be negated with the "no-" prefix, --no-verbose is the same as --verbose=false.
+	 A *bool field is tri-state: it stays nil when it is not set by any source, so a value set by a parser
+	 is never overridden by a zero default.
+
 	 Loading Configuration:
 
 	 Load the configuration using the config.Process function. It will read environment variables and command-line flags, applying any specified mutators.
@@ -76,9 +105,134 @@ Usage:
 		    // Your application logic using cfg
 		}
 
+	 Using a Loader:
+
+	 Process and ProcessWithParser are shortcuts for a Loader with the default options. A Loader can be
+	 configured with options, for example the strict mode that rejects unknown flags and unknown
+	 environment variables with the application prefix, suggesting the closest known names. WithDelimiter and
+	 WithSeparator set the delimiters of the fields without the delim and kvsep tags, StartupMessage accepts
+	 the same options so its output can be decoded back.
+
+		l := config.NewLoader(
+		    config.WithParsers(yaml.WithData(data)),
+		    config.WithStrict(),
+		    config.WithEnvPrefix("APP"),
+		)
+		if err := l.Load(&cfg); err != nil {
+		    // unknown flag: --prot (did you mean --port?)
+		}
+
+	 Hot Reload:
+
+	 A Watcher reloads the configuration when the watched files change or, with WithReloadSignal, on SIGHUP.
+	 Every reload runs the full pipeline of the Loader and swaps the new configuration into a Value,
+	 an invalid configuration is never applied and its error is passed to the function set with WithReloadError.
+
+		w, err := config.NewWatcher[AppConfig](config.WithParsers(yaml.File("config.yaml")), config.WithReloadSignal())
+		w.Value().Subscribe(func(old, new AppConfig) {
+		    logger.SetLevel(new.LogLevel)
+		})
+		go w.Run(ctx)
+
+	 A reload changing the static fields, tagged with reload:"false", applies the other fields and returns
+	 a *RestartError matching ErrRestartRequired. Watcher.Subscribe passes the changes of the fields to the callback.
+
+	 Diff:
+
+	 Diff compares two configurations and returns the added, removed and changed fields by environment
+	 variable and flag, with the masked values hashed. The changes render as text, unified diff or JSON.
+
+		changes, err := config.Diff(&staging, &production)
+		fmt.Print(changes.Text())
+
+	 Usage Message:
+
+	 UsageMessage generates the usage message and PrintUsage writes it to the output of the Loader. The options
+	 are grouped by nested struct and the hidden fields are left out. WithProgramName, WithDescription,
+	 WithUsageHeader, WithUsageFooter, WithExamples, WithUsageTemplate and WithWrapWidth customize the message.
+
+		err := config.PrintUsage(&cfg, config.WithProgramName("app"), config.WithExamples("app --port 9090"))
+
+	 Reference Documentation:
+
+	 Markdown and ManPage generate the reference of every field with its environment variable, flags, type,
+	 default, validation rules, deprecation and usage. The fields of nested structs are listed in their own sections.
+
+		md, err := config.Markdown(&cfg)
+		man, err := config.ManPage(&cfg, version)
+
+	 JSON Schema:
+
+	 JSONSchema generates the JSON Schema (draft 2020-12) of the configuration files with the properties named
+	 after the yaml or json tags. It covers the types, defaults, oneof values, min and max bounds and usage.
+
+		s, err := config.JSONSchema(&cfg)
+
+	 Sample Configuration:
+
+	 Sample generates a sample configuration file in yaml, json, toml or env format with the default values,
+	 placeholders for the masked strings and the usage, required flag and validation rules as comments. The
+	 lines of the other masked fields and of the values which can't be loaded back are commented out, so the
+	 sample loads back.
+	 The hidden --print-sample-config=<format> flag writes it to the output of the Loader and returns ErrSampleConfig.
+
+		s, err := config.Sample(&cfg, "yaml")
+
+	 Export:
+
+	 Export writes the effective configuration in yaml, json, env or args format. The yaml and json documents
+	 are nested like the structs with typed values and can be loaded back with the yaml parser. ExportArgs
+	 returns the command line flags. The masked fields are masked unless WithExportMask(MaskNone) is given.
+
+		out, err := config.Export(&cfg, "yaml")
+		args, err := config.ExportArgs(&cfg, config.WithExportMask(config.MaskNone))
+
+	 Kubernetes Manifests:
+
+	 KubernetesManifests generates the ConfigMap of the fields which are not masked and the Secret stub of the
+	 masked fields, with the keys commented out. KubernetesEnv generates the envFrom and env block of the
+	 container referencing them, the references of the required fields and secrets are not optional.
+
+		manifests, err := config.KubernetesManifests(&cfg, "app")
+		env, err := config.KubernetesEnv(&cfg, "app")
+
+	 Subcommands:
+
+	 Commands dispatches the command line to subcommands. Each command binds its own configuration struct
+	 and shares the root struct, whose flags can be given before or after the name of the command.
+	 The usage message of each level is written to the output set with WithOutput on --help and ErrHelp
+	 is returned.
+
+		var global GlobalConfig
+		var serve ServeConfig
+		cmds := config.Commands{
+		    Root: &global,
+		    Commands: []config.Command{
+		        {Name: "serve", Usage: "start the server", Config: &serve, Run: func(ctx context.Context) error {
+		            return runServer(ctx, global, serve)
+		        }},
+		    },
+		}
+		if err := cmds.Execute(ctx); err != nil {
+		    // Handle error
+		}
+
+	 Shell Completion:
+
+	 Completion generates bash, zsh and fish completion scripts from the fields. The values of the fields
+	 with the oneof tag are completed from the list, the fields with the complete tag are completed with
+	 file or directory names. The hidden --completion=<shell> flag writes the script to the output of
+	 the Loader and returns ErrCompletion.
+
+		script, err := config.Completion(&cfg, "bash")
+
 	 Custom Decoders:
 
 	 The Decoder interface declares the Decode method, which can be implemented to provide custom decoding logic.
+	 Decoder, encoding.TextUnmarshaler, json.Unmarshaler, encoding.BinaryUnmarshaler and gob.GobDecoder are
+	 honored for any field type, including the elements of slices and maps and the targets of pointers,
+	 and take precedence over the built-in decoding. A json.Unmarshaler gets the plain values as JSON strings,
+	 and a JSON number or boolean as it is first. Base64Bytes and HexBytes are provided by the package.
 
 		type Decoder interface {
 		    Decode(val string) error
@@ -102,6 +256,47 @@ Usage:
 		    return nil
 		}
 
+	 Supported Types:
+
+	 Besides strings, booleans, numbers, complex numbers, time.Duration, slices, arrays (of the exact length)
+	 and maps, the package supports url.URL, net.IP, net.IPNet (CIDR), netip.Addr, netip.Prefix, time.Time
+	 (with the layout tag), *time.Location, slog.Level, os.FileMode (octal, for example 0644) and
+	 *regexp.Regexp out of the box, with pointers to them.
+	 The yaml parser implements TextParser and leaves the values of these types, which the yaml decoder can't
+	 decode, to the Loader, so they are written in yaml the same way as in the environment variables.
+	 ByteSize accepts human-readable sizes like 512KiB, 10MB or 1.5GiB and Rate accepts rates like 100/s or 5000/min.
+
+	 Slices and Maps of Structs:
+
+	 The fields of the elements of slices and maps of structs are set with indexed environment variables
+	 and flags, for example UPSTREAMS_0_HOST or --upstreams.0.host for []Upstream and BACKENDS_PRIMARY_URL
+	 or --backends.primary.url for map[string]Backend. The elements given by the parsers are kept and
+	 their fields overridden, an index beyond them appends a new element, so sparse indices are compacted.
+	 The map keys given with environment variables are lowercased, the keys of the environment variables and
+	 flags match the existing keys case-insensitively.
+
+	 Optional Sections:
+
+	 A nil pointer to a struct is an optional section, it is allocated only when any of its fields is set
+	 by an environment variable or a flag, so a nil pointer means the section is not configured. Defaults
+	 alone don't allocate the section and the required fields of an unset section are not enforced.
+
+		type Config struct {
+		    TLS *TLSConfig // nil unless TLS_CERT, --tls-cert, ... is given
+		}
+
+	 Custom Types:
+
+	 Types which can't implement the Decoder interface, for example the types of other packages, can be
+	 registered with RegisterType for every Loader or with the WithType option for a single Loader.
+	 RegisterFormatter registers the matching formatter used by the startup message.
+
+		config.RegisterType(func(s string) (*big.Float, error) {
+		    f, _, err := big.ParseFloat(s, 10, 256, big.ToNearestEven)
+		    return f, err
+		})
+		config.RegisterFormatter(func(f *big.Float) string { return f.String() })
+
 	 Mutator Functions:
 
 	 The MutatorFunc is a function type that mutates a value of a key before it is set to the field.
@@ -29,6 +29,11 @@ func (b *Base64Bytes) Bytes() []byte {
 	return []byte(*b)
 }
 
+// String returns the base64 encoded value.
+func (b Base64Bytes) String() string {
+	return base64.StdEncoding.EncodeToString(b)
+}
+
 // HexBytes is a type that can be used to decode hex encoded
 type HexBytes []byte
 
@@ -47,3 +52,8 @@ func (h *HexBytes) Decode(val string) error {
 func (h *HexBytes) Bytes() []byte {
 	return []byte(*h)
 }
+
+// String returns the hex encoded value.
+func (h HexBytes) String() string {
+	return hex.EncodeToString(h)
+}
@@ -11,7 +11,7 @@ type env struct {
 }
 
 // newEnvSource returns a new Parser that can be used to process the conf struct with environment variables.
-func newEnvSource() source {
+func newEnvSource() *env {
 	// iterate over os.Environ and store the environment variables in a map
 	m := make(map[string]string)
 	for _, e := range os.Environ() {
@@ -36,3 +36,8 @@ func (e *env) Source(f Field) (string, bool) {
 
 	return "", false
 }
+
+// origin returns the name of the environment variable of the field.
+func (e *env) origin(f Field) string {
+	return "environment variable " + f.EnvVar
+}
@@ -0,0 +1,364 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// object is a JSON object keeping the order of its keys, the fields are exported in the order
+// of the struct.
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// newObject returns an empty object.
+func newObject() *object {
+	return &object{values: make(map[string]interface{})}
+}
+
+// set sets the value of the key.
+func (o *object) set(key string, v interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = v
+}
+
+// child returns the nested object with the key, it is added when it is missing.
+func (o *object) child(key string) *object {
+	if c, ok := o.values[key].(*object); ok {
+		return c
+	}
+
+	c := newObject()
+	o.set(key, c)
+
+	return c
+}
+
+// MarshalJSON encodes the object with the keys in order.
+func (o *object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := marshalJSON(k)
+		if err != nil {
+			return nil, err
+		}
+		value, err := marshalJSON(o.values[k])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+
+	return b.Bytes(), nil
+}
+
+// Export writes the effective configuration in the given format: yaml, json, env or args. The yaml
+// and json documents are nested like the structs with the keys named after the yaml or json tags and
+// the values keep their types, they are loadable with the yaml parser. The env format lists the
+// environment variables and the args format the command line flags, quoted for the shell.
+//
+// The masked fields are masked with their own mode, WithExportMask(config.MaskNone) exports their
+// values as is. The options of the Loader, like WithDelimiter, apply to the env and args formats.
+func Export(cfg interface{}, format string, opts ...Option) (string, error) {
+	l := NewLoader(opts...)
+
+	switch format {
+	case "yaml", "yml", "json":
+		fields, err := l.extractFields(nil, cfg)
+		if err != nil {
+			return "", err
+		}
+		obj, err := l.exportObject(fields)
+		if err != nil {
+			return "", err
+		}
+
+		if format == "json" {
+			b, err := marshalJSON(obj)
+			if err != nil {
+				return "", err
+			}
+			var out bytes.Buffer
+			if err := json.Indent(&out, b, "", "  "); err != nil {
+				return "", err
+			}
+			return out.String() + "\n", nil
+		}
+
+		var sb strings.Builder
+		if err := writeYAML(&sb, obj, ""); err != nil {
+			return "", err
+		}
+		return sb.String(), nil
+	case "env":
+		var sb strings.Builder
+		err := l.exportFields(cfg, func(f Field, value string) {
+			fmt.Fprintf(&sb, "%s=%s\n", f.EnvVar, envQuote(value))
+		})
+		if err != nil {
+			return "", err
+		}
+		return sb.String(), nil
+	case "args":
+		args, err := ExportArgs(cfg, opts...)
+		if err != nil {
+			return "", err
+		}
+		for i := range args {
+			args[i] = shellQuote(args[i])
+		}
+		return strings.Join(args, " ") + "\n", nil
+	}
+
+	return "", errors.New("unsupported export format: " + format)
+}
+
+// ExportArgs returns the effective configuration as the command line flags, for example to start
+// a child process with the same configuration. The masked fields are masked like with Export.
+func ExportArgs(cfg interface{}, opts ...Option) ([]string, error) {
+	l := NewLoader(opts...)
+
+	var args []string
+	err := l.exportFields(cfg, func(f Field, value string) {
+		args = append(args, "--"+f.Flag+"="+value)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return args, nil
+}
+
+// exportFields calls the function with the fields in use and their masked values in the format of
+// the environment variables and the flags. The unset fields without a default are left out.
+func (l *Loader) exportFields(cfg interface{}, fn func(f Field, value string)) error {
+	fields, _, err := l.currentFields(cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		if f.Args {
+			continue
+		}
+
+		value := valueToString(f.FieldValue, l.options(f))
+		if value == "" && f.Default == "" {
+			continue
+		}
+		fn(f, maskString(value, l.maskMode(f)))
+	}
+
+	return nil
+}
+
+// maskMode returns the mask mode of the exported field.
+func (l *Loader) maskMode(f Field) MaskMode {
+	if l.exportMask != nil && f.Mask {
+		return *l.exportMask
+	}
+
+	return f.MaskMode
+}
+
+// exportObject returns the fields read from the configuration files as an object nested like the
+// structs. The fields of the optional sections which are not set are left out.
+func (l *Loader) exportObject(fields []Field) (*object, error) {
+	root := newObject()
+	for _, f := range fields {
+		if f.Args || len(f.path) == 0 || !f.section.active() {
+			continue
+		}
+
+		v, err := l.exportValue(f)
+		if err != nil {
+			return nil, err
+		}
+
+		o := root
+		for _, key := range f.path[:len(f.path)-1] {
+			o = o.child(key)
+		}
+		o.set(f.path[len(f.path)-1], v)
+	}
+
+	return root, nil
+}
+
+// exportValue returns the value of the field as a JSON value, the masked values are strings.
+func (l *Loader) exportValue(f Field) (interface{}, error) {
+	v := f.FieldValue
+	if isStructCollection(l.types, v.Type()) {
+		return l.exportCollection(v)
+	}
+
+	opts := l.options(f)
+	if mode := l.maskMode(f); mode != MaskNone {
+		return maskString(valueToString(v, opts), mode), nil
+	}
+
+	return jsonValue(v, opts), nil
+}
+
+// exportCollection returns the elements of the slice or the map of structs, the map elements are
+// in the order of their keys.
+func (l *Loader) exportCollection(v reflect.Value) (interface{}, error) {
+	element := func(e reflect.Value) (interface{}, error) {
+		if e.Kind() == reflect.Ptr {
+			if e.IsNil() {
+				return nil, nil
+			}
+		} else {
+			p := reflect.New(e.Type())
+			p.Elem().Set(e)
+			e = p
+		}
+
+		fields, err := l.extractFields(nil, e.Interface())
+		if err != nil {
+			return nil, err
+		}
+		return l.exportObject(fields)
+	}
+
+	if v.Kind() == reflect.Slice {
+		list := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			e, err := element(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, e)
+		}
+		return list, nil
+	}
+
+	keys := make(map[string]reflect.Value, v.Len())
+	names := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		name := valueToString(k, fieldOptions{})
+		keys[name] = k
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	obj := newObject()
+	for _, name := range names {
+		e, err := element(v.MapIndex(keys[name]))
+		if err != nil {
+			return nil, err
+		}
+		obj.set(name, e)
+	}
+
+	return obj, nil
+}
+
+// plainKey matches the yaml keys written without quotes.
+var plainKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// writeYAML writes the value in yaml: the objects and the lists of objects in the block style,
+// the other values in the JSON flow style.
+func writeYAML(sb *strings.Builder, v interface{}, indent string) error {
+	switch v := v.(type) {
+	case *object:
+		for _, k := range v.keys {
+			key := k
+			if !plainKey.MatchString(k) {
+				b, err := marshalJSON(k)
+				if err != nil {
+					return err
+				}
+				key = string(b)
+			}
+
+			value := v.values[k]
+			if !isYAMLBlock(value) {
+				b, err := marshalJSON(value)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(sb, "%s%s: %s\n", indent, key, b)
+				continue
+			}
+
+			fmt.Fprintf(sb, "%s%s:\n", indent, key)
+			if err := writeYAML(sb, value, indent+"  "); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, e := range v {
+			if !isYAMLBlock(e) {
+				b, err := marshalJSON(e)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(sb, "%s- %s\n", indent, b)
+				continue
+			}
+
+			// the first line of the element starts with the dash
+			var item strings.Builder
+			if err := writeYAML(&item, e, indent+"  "); err != nil {
+				return err
+			}
+			sb.WriteString(indent + "- " + strings.TrimPrefix(item.String(), indent+"  "))
+		}
+	}
+
+	return nil
+}
+
+// isYAMLBlock reports whether the value is written in the block style: the non-empty objects and
+// the non-empty lists of objects.
+func isYAMLBlock(v interface{}) bool {
+	switch v := v.(type) {
+	case *object:
+		return len(v.keys) > 0
+	case []interface{}:
+		for _, e := range v {
+			if isYAMLBlock(e) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// envQuote quotes the value of the environment variable when it has spaces, quotes or the
+// characters interpreted by the .env files.
+func envQuote(s string) string {
+	if strings.ContainsAny(s, " \t\n\"'#$\\") {
+		b, _ := marshalJSON(s)
+		return string(b)
+	}
+
+	return s
+}
+
+// shellQuote quotes the argument for the shell when it has characters other than the safe ones.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=.,:/@+%") == "" {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -25,24 +26,133 @@ const (
 	shortFlagTag     = "shortFlag"
 	usageTag         = "usage"
 	maskTag          = "mask"
+	argsTag          = "args"
+	delimiterTag     = "delim"
+	oneOfTag         = "oneof"
+	completeTag      = "complete"
+	layoutTag        = "layout"
+	minTag           = "min"
+	maxTag           = "max"
+	formatTag        = "format"
+	separatorTag     = "kvsep"
+	subDelimiterTag  = "subdelim"
+	reloadTag        = "reload"
+	deprecatedTag    = "deprecated"
+	hiddenTag        = "hidden"
 	delimiter        = ","
+	subDelimiter     = ";"
 	separator        = ":"
 )
 
 type Field struct {
-	FieldValue reflect.Value
-	Name       string
-	EnvVar     string
-	Flag       string
-	ShortFlag  rune
-	Default    string
-	Required   bool
-	Mask       bool
-	Usage      string
+	FieldValue   reflect.Value
+	Name         string
+	EnvVar       string
+	Flag         string
+	ShortFlag    rune
+	Default      string
+	Required     bool
+	Mask         bool
+	MaskMode     MaskMode
+	Usage        string
+	Args         bool
+	Delimiter    string
+	SubDelimiter string
+	Separator    string
+	OneOf        []string
+	Complete     string
+	Layout       string
+	Min          string
+	Max          string
+	Format       string
+	Static       bool
+	Deprecated   string
+	Hidden       bool
+
+	// Group is the path of the nested struct the field belongs to, for example "HTTP" or "TLS.Client",
+	// empty for the top level fields and the fields of embedded structs
+	Group string
+
+	// path is the path of the field in the configuration files given with the yaml or json tags, for
+	// example [http tls cert], nil when the field is left out of the files
+	path []string
+
+	// section is the optional section the field belongs to, nil when the field is always in use
+	section *section
 }
 
-// extractFields parses the struct and returns the list of Fields.
-func extractFields(prefix []string, targetStruct interface{}) ([]Field, error) {
+// fieldOptions holds the per-field settings used by processField to decode a value.
+type fieldOptions struct {
+	delimiter    string
+	subDelimiter string
+	separator    string
+	layout       string
+	format       string
+	types        typeRegistry
+}
+
+// options returns the settings used to decode the value of the field.
+func (f Field) options() fieldOptions {
+	opts := fieldOptions{
+		delimiter:    f.Delimiter,
+		subDelimiter: f.SubDelimiter,
+		separator:    f.Separator,
+		layout:       f.Layout,
+		format:       f.Format,
+	}
+	if opts.delimiter == "" {
+		opts.delimiter = delimiter
+	}
+	if opts.separator == "" {
+		opts.separator = separator
+	}
+
+	return opts
+}
+
+// splitKeyValue splits the map entry into the key and the value at the separator. With the default
+// separator ":" the key can be given with "=" too, for example --label env=prod, the entry is split at
+// whichever comes first.
+func splitKeyValue(entry, sep string) []string {
+	if sep != separator {
+		return strings.SplitN(entry, sep, 2)
+	}
+
+	if i := strings.IndexAny(entry, ":="); i >= 0 {
+		return []string{entry[:i], entry[i+1:]}
+	}
+
+	return []string{entry}
+}
+
+// elements returns the settings used to decode the elements of slices, arrays and maps, the nested
+// collections are split with the sub-delimiter, for example a;b,c;d for [][]string. Default
+// sub-delimiter is ";", or "," when the delimiter is ";".
+func (o fieldOptions) elements() fieldOptions {
+	switch {
+	case o.subDelimiter != "":
+		o.delimiter = o.subDelimiter
+	case o.delimiter == subDelimiter:
+		o.delimiter = delimiter
+	default:
+		o.delimiter = subDelimiter
+	}
+
+	return o
+}
+
+// timeLayout returns the layout used to parse time.Time values, default is time.RFC3339.
+func (o fieldOptions) timeLayout() string {
+	if o.layout == "" {
+		return time.RFC3339
+	}
+
+	return o.layout
+}
+
+// extractFields parses the struct and returns the list of Fields. The struct types registered for
+// the Loader with WithType are leaf fields like the types registered with RegisterType.
+func (l *Loader) extractFields(prefix []string, targetStruct interface{}) ([]Field, error) {
 	if prefix == nil {
 		prefix = []string{}
 	}
@@ -77,10 +187,29 @@ func extractFields(prefix []string, targetStruct interface{}) ([]Field, error) {
 		requiredValue := sf.Tag.Get(requiredValueTag)
 		maskValue := sf.Tag.Get(maskTag)
 		usageValue := sf.Tag.Get(usageTag)
+		argsValue := sf.Tag.Get(argsTag)
+		delimiterValue := sf.Tag.Get(delimiterTag)
+		separatorValue := sf.Tag.Get(separatorTag)
+		subDelimiterValue := sf.Tag.Get(subDelimiterTag)
+		oneOfValue := sf.Tag.Get(oneOfTag)
+		completeValue := sf.Tag.Get(completeTag)
+		layoutValue := sf.Tag.Get(layoutTag)
+		formatValue := sf.Tag.Get(formatTag)
+		reloadValue := sf.Tag.Get(reloadTag)
+		deprecatedValue := sf.Tag.Get(deprecatedTag)
+		hiddenValue := sf.Tag.Get(hiddenTag)
+		minValue := sf.Tag.Get(minTag)
+		maxValue := sf.Tag.Get(maxTag)
 
 		fieldName := sf.Name
 		fieldKey := append(prefix, splitCamelCase(fieldName)...)
 
+		var path []string
+		fileName, inline := fileKey(sf)
+		if fileName != "" {
+			path = []string{fileName}
+		}
+
 		envName, err := createOrValidateEnvVarName(envVar, fieldKey)
 		if err != nil {
 			return nil, err
@@ -100,82 +229,216 @@ func extractFields(prefix []string, targetStruct interface{}) ([]Field, error) {
 			short = []rune(shortFlag)[0]
 		}
 
+		mask, err := parseMaskMode(maskValue, envName, f.Type(), l.maskPatterns)
+		if err != nil {
+			return nil, err
+		}
+
+		// Positional arguments can only be collected into a slice of strings
+		if argsValue == "true" && f.Type() != reflect.TypeOf([]string(nil)) {
+			return nil, fmt.Errorf("positional arguments field %s must be of type []string", fieldName)
+		}
+
+		// Validate the completion hint
+		if completeValue != "" && completeValue != "file" && completeValue != "dir" {
+			return nil, errors.New("invalid completion hint has been provided: " + completeValue)
+		}
+
+		// Validate the format of the value
+		if formatValue != "" && formatValue != formatJSON {
+			return nil, errors.New("invalid format has been provided: " + formatValue)
+		}
+
 		// Check if field is required and has a default value
 		if requiredValue == "true" && defaultValue != "" {
 			return nil, fmt.Errorf("required field %s cannot have a default value", fieldName)
 		}
 
 		field := Field{
-			FieldValue: f,
-			Name:       strings.Join(fieldKey, "_"),
-			EnvVar:     envName,
-			Flag:       flag,
-			ShortFlag:  short,
-			Default:    defaultValue,
-			Required:   requiredValue == "true",
-			Mask:       maskValue == "true",
-			Usage:      usageValue,
+			FieldValue:   f,
+			Name:         strings.Join(fieldKey, "_"),
+			EnvVar:       envName,
+			Flag:         flag,
+			ShortFlag:    short,
+			Default:      defaultValue,
+			Required:     requiredValue == "true",
+			Mask:         mask != MaskNone,
+			MaskMode:     mask,
+			Usage:        usageValue,
+			Args:         argsValue == "true",
+			Delimiter:    delimiterValue,
+			SubDelimiter: subDelimiterValue,
+			Separator:    separatorValue,
+			OneOf:        strings.Fields(oneOfValue),
+			Complete:     completeValue,
+			Layout:       layoutValue,
+			Format:       formatValue,
+			Static:       reloadValue == "false",
+			Deprecated:   deprecatedValue,
+			Hidden:       hiddenValue == "true",
+			Min:          minValue,
+			Max:          maxValue,
+			path:         path,
 		}
 
 		fields = append(fields, field)
 
-		// Drill down through struct fields
-		if f.Kind() == reflect.Struct {
-			// Process value with decoder
-			if err := processDecoder("", f); err != nil {
-				return nil, errors.New("error processing decoder for FieldValue: " + sf.Name + " " + err.Error())
-			}
-
+		// Drill down through struct fields, structs decoded as a whole are leaf fields
+		if f.Kind() == reflect.Struct && !isLeaf(l.types, f.Type()) && formatValue != formatJSON {
 			innerPrefix := fieldKey
 			if sf.Anonymous {
 				innerPrefix = prefix
 			}
 
 			embeddedPtr := f.Addr().Interface()
-			embeddedFields, err := extractFields(innerPrefix, embeddedPtr)
+			embeddedFields, err := l.extractFields(innerPrefix, embeddedPtr)
 			if err != nil {
 				return nil, errors.New("error parsing embedded struct for FieldValue: " + sf.Name + " " + err.Error())
 			}
+			markStatic(embeddedFields, field.Static)
+			markHidden(embeddedFields, field.Hidden)
+			if !sf.Anonymous {
+				inGroup(embeddedFields, sf.Name)
+			}
+			inFile(embeddedFields, fileName, inline)
 			fields = append(fields[:len(fields)-1], embeddedFields...)
 			continue
 		}
 
+		// Drill down through pointers to structs, a nil pointer is an optional section
+		// allocated only when any of its fields is set
+		if isSection(l.types, f.Type()) && formatValue != formatJSON {
+			innerPrefix := fieldKey
+			if sf.Anonymous {
+				innerPrefix = prefix
+			}
+
+			target := f
+			var sec *section
+			if f.IsNil() {
+				sec = &section{field: f, value: reflect.New(f.Type().Elem())}
+				target = sec.value
+			}
+
+			sectionFields, err := l.extractFields(innerPrefix, target.Interface())
+			if err != nil {
+				return nil, errors.New("error parsing section for FieldValue: " + sf.Name + " " + err.Error())
+			}
+			if sec != nil {
+				inSection(sectionFields, sec)
+			}
+			markStatic(sectionFields, field.Static)
+			markHidden(sectionFields, field.Hidden)
+			if !sf.Anonymous {
+				inGroup(sectionFields, sf.Name)
+			}
+			inFile(sectionFields, fileName, inline)
+			fields = append(fields[:len(fields)-1], sectionFields...)
+			continue
+		}
+
 	}
 
 	return fields, nil
 }
 
-// processDecoder processes the Field as a decoder or custom unmarshaler.
-func processDecoder(value string, field reflect.Value) error {
-	if field.CanAddr() {
-		field = field.Addr()
+// decoderTypes are the interfaces used to decode a field with custom logic, in the order of precedence.
+var decoderTypes = []reflect.Type{
+	reflect.TypeOf((*Decoder)(nil)).Elem(),
+	reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem(),
+	reflect.TypeOf((*json.Unmarshaler)(nil)).Elem(),
+	reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem(),
+	reflect.TypeOf((*gob.GobDecoder)(nil)).Elem(),
+}
+
+// isLeaf reports whether the struct type is decoded as a whole instead of field by field: it is a
+// built-in type, a type registered with RegisterType or in the local registry of the Loader, or it
+// implements one of the decoder interfaces.
+func isLeaf(local typeRegistry, t reflect.Type) bool {
+	if _, ok := builtinTypes[t]; ok {
+		return true
+	}
+
+	if _, ok := lookupType(local, t); ok {
+		return true
 	}
 
-	iface := field.Interface()
+	return isDecoder(t)
+}
+
+// isDecoder reports whether the pointer to the type implements one of the decoder interfaces.
+func isDecoder(t reflect.Type) bool {
+	pt := reflect.PointerTo(t)
+	for _, d := range decoderTypes {
+		if pt.Implements(d) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// processDecoder processes the Field as a decoder or custom unmarshaler. It reports whether the
+// field implements one of the decoder interfaces, in that case the value is decoded by the field itself.
+func processDecoder(value string, field reflect.Value) (bool, error) {
+	if !field.CanAddr() {
+		return false, nil
+	}
+
+	iface := field.Addr().Interface()
 	switch iface := iface.(type) {
 	case Decoder:
-		return iface.Decode(value)
+		return true, iface.Decode(value)
 	case encoding.TextUnmarshaler:
-		return iface.UnmarshalText([]byte(value))
+		return true, iface.UnmarshalText([]byte(value))
 	case json.Unmarshaler:
-		return iface.UnmarshalJSON([]byte(value))
+		return true, unmarshalJSON(iface, value)
 	case encoding.BinaryUnmarshaler:
-		return iface.UnmarshalBinary([]byte(value))
+		return true, iface.UnmarshalBinary([]byte(value))
 	case gob.GobDecoder:
-		return iface.GobDecode([]byte(value))
+		return true, iface.GobDecode([]byte(value))
 	}
 
-	return nil
+	return false, nil
 }
 
-// processField processes the Field as a string.
-func processField(value string, field reflect.Value) error {
+// processField processes the Field as a string. Slices and maps are split with the delimiter from the options.
+// The types registered with RegisterType or WithType and the custom decoders take precedence over the
+// built-in decoding for any type, including the elements of slices and maps and the targets of pointers.
+func processField(value string, field reflect.Value, opts fieldOptions) error {
+	// the fields with the json format are always decoded as JSON
+	if opts.format == formatJSON {
+		return processJSON(value, field)
+	}
+
+	// registered types take precedence, then the built-in types
+	for _, process := range []func(string, reflect.Value, fieldOptions) (bool, error){processType, processBuiltin} {
+		if ok, err := process(value, field, opts); ok {
+			if err != nil {
+				return errors.New("error decoding " + field.Type().String() + ": " + err.Error())
+			}
+			return nil
+		}
+	}
+
 	// handle pointers and uninitialized pointers
 	if field.Kind() == reflect.Ptr {
 		if field.IsNil() {
 			field.Set(reflect.New(field.Type().Elem()))
 		}
-		field = field.Elem()
+		return processField(value, field.Elem(), opts)
+	}
+
+	if ok, err := processDecoder(value, field); ok {
+		if err != nil {
+			return errors.New("error decoding " + field.Type().String() + ": " + err.Error())
+		}
+		return nil
+	}
+
+	// JSON literals for the values which can't be expressed with delimiters
+	if isJSONLiteral(value, field.Kind()) {
+		return processJSON(value, field)
 	}
 
 	switch field.Kind() {
@@ -216,26 +479,53 @@ func processField(value string, field reflect.Value) error {
 			return errors.New("error parsing float: " + err.Error())
 		}
 		field.SetFloat(i)
+	case reflect.Complex64, reflect.Complex128:
+		c, err := strconv.ParseComplex(value, field.Type().Bits())
+		if err != nil {
+			return errors.New("error parsing complex: " + err.Error())
+		}
+		field.SetComplex(c)
+	case reflect.Array:
+		// special case for [N]byte, the value is the raw bytes
+		if field.Type().Elem().Kind() == reflect.Uint8 {
+			if len(value) != field.Len() {
+				return fmt.Errorf("invalid length of %s: %d bytes, must be %d", field.Type(), len(value), field.Len())
+			}
+			reflect.Copy(field, reflect.ValueOf([]byte(value)))
+			return nil
+		}
+
+		vals := strings.Split(value, opts.delimiter)
+		if len(vals) != field.Len() {
+			return fmt.Errorf("invalid length of %s: %d elements, must be %d", field.Type(), len(vals), field.Len())
+		}
+		a := reflect.New(field.Type()).Elem()
+		for i, v := range vals {
+			if err := processField(strings.TrimSpace(v), a.Index(i), opts.elements()); err != nil {
+				return err
+			}
+		}
+		field.Set(a)
 	case reflect.Slice:
 		// special case for []byte
 		if field.Type().Elem().Kind() == reflect.Uint8 {
 			field.Set(reflect.ValueOf([]byte(value)))
 		} else {
-			vals := strings.Split(value, delimiter)
+			vals := strings.Split(value, opts.delimiter)
 			s := reflect.MakeSlice(field.Type(), len(vals), len(vals))
 			for i, v := range vals {
 				v = strings.TrimSpace(v)
-				if err := processField(v, s.Index(i)); err != nil {
+				if err := processField(v, s.Index(i), opts.elements()); err != nil {
 					return err
 				}
 			}
 			field.Set(s)
 		}
 	case reflect.Map:
-		vals := strings.Split(value, delimiter)
+		vals := strings.Split(value, opts.delimiter)
 		mp := reflect.MakeMapWithSize(field.Type(), len(vals))
 		for _, v := range vals {
-			kv := strings.SplitN(v, separator, 2)
+			kv := splitKeyValue(v, opts.separator)
 			if len(kv) != 2 {
 				return errors.New("invalid map value: " + v)
 			}
@@ -243,12 +533,12 @@ func processField(value string, field reflect.Value) error {
 			mKey, mVal := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
 
 			k := reflect.New(field.Type().Key()).Elem()
-			if err := processField(mKey, k); err != nil {
+			if err := processField(mKey, k, opts); err != nil {
 				return err
 			}
 
 			v := reflect.New(field.Type().Elem()).Elem()
-			if err := processField(mVal, v); err != nil {
+			if err := processField(mVal, v, opts.elements()); err != nil {
 				return err
 			}
 
@@ -262,10 +552,39 @@ func processField(value string, field reflect.Value) error {
 	return nil
 }
 
-// valueToString accepts a reflect.Value and returns a string representation of it.
-func valueToString(v reflect.Value) string {
+// valueToString accepts a reflect.Value and returns a string representation of it. Slices and maps
+// are joined with the delimiter and the separator of the options, so the result can be decoded back
+// with the same options.
+func valueToString(v reflect.Value, opts fieldOptions) string {
+	// the fields with the json format are encoded as JSON, so they are decoded back as JSON
+	if opts.format == formatJSON && v.IsValid() {
+		if b, err := json.Marshal(v.Interface()); err == nil {
+			return string(b)
+		}
+	}
+
 	if v.IsValid() {
+		// registered formatters take precedence
+		if fn, ok := lookupFormatter(v.Type()); ok {
+			return fn(v)
+		}
+
+		if s, ok := formatBuiltin(v, opts); ok {
+			return s
+		}
+
+		// custom types render themselves
+		if s, ok := formatValue(v); ok {
+			return s
+		}
+
 		switch v.Kind() {
+		case reflect.Ptr:
+			// nil pointer is an unset value, for example tri-state *bool
+			if v.IsNil() {
+				return ""
+			}
+			return valueToString(v.Elem(), opts)
 		case reflect.String:
 			return v.String()
 		case reflect.Bool:
@@ -280,22 +599,28 @@ func valueToString(v reflect.Value) string {
 			return strconv.FormatUint(v.Uint(), 10)
 		case reflect.Float32, reflect.Float64:
 			return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits())
-		case reflect.Slice:
-			// special case for []byte
+		case reflect.Complex64, reflect.Complex128:
+			return strconv.FormatComplex(v.Complex(), 'f', -1, v.Type().Bits())
+		case reflect.Slice, reflect.Array:
+			// special case for []byte and [N]byte
 			if v.Type().Elem().Kind() == reflect.Uint8 {
-				return string(v.Bytes())
+				b := make([]byte, v.Len())
+				reflect.Copy(reflect.ValueOf(b), v)
+				return string(b)
 			}
 			var vals []string
 			for i := 0; i < v.Len(); i++ {
-				vals = append(vals, valueToString(v.Index(i)))
+				vals = append(vals, valueToString(v.Index(i), opts.elements()))
 			}
-			return strings.Join(vals, delimiter)
+			return strings.Join(vals, opts.delimiter)
 		case reflect.Map:
 			var vals []string
 			for _, k := range v.MapKeys() {
-				vals = append(vals, valueToString(k)+separator+valueToString(v.MapIndex(k)))
+				vals = append(vals, valueToString(k, opts)+opts.separator+valueToString(v.MapIndex(k), opts.elements()))
 			}
-			return strings.Join(vals, delimiter)
+			// sort the pairs, so the output is stable
+			sort.Strings(vals)
+			return strings.Join(vals, opts.delimiter)
 		default:
 			return ""
 		}
@@ -304,6 +629,104 @@ func valueToString(v reflect.Value) string {
 	return ""
 }
 
+// formatValue formats the value with encoding.TextMarshaler or fmt.Stringer if the value implements one of them.
+func formatValue(v reflect.Value) (string, bool) {
+	if v.Kind() == reflect.Ptr || !v.CanInterface() {
+		return "", false
+	}
+
+	// special case for time.Duration, it is formatted by valueToString
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		return "", false
+	}
+
+	ifaces := []interface{}{v.Interface()}
+	if v.CanAddr() {
+		ifaces = append(ifaces, v.Addr().Interface())
+	}
+
+	for _, iface := range ifaces {
+		switch iface := iface.(type) {
+		case encoding.TextMarshaler:
+			b, err := iface.MarshalText()
+			if err != nil {
+				return "", false
+			}
+			return string(b), true
+		case fmt.Stringer:
+			return iface.String(), true
+		}
+	}
+
+	return "", false
+}
+
+// markHidden hides the fields of the nested struct tagged with hidden:"true".
+func markHidden(fields []Field, hidden bool) {
+	if !hidden {
+		return
+	}
+
+	for i := range fields {
+		fields[i].Hidden = true
+	}
+}
+
+// inGroup places the fields of the nested struct in the group named after the struct field.
+func inGroup(fields []Field, name string) {
+	for i := range fields {
+		if fields[i].Group == "" {
+			fields[i].Group = name
+		} else {
+			fields[i].Group = name + "." + fields[i].Group
+		}
+	}
+}
+
+// fileKey returns the key of the struct field in the configuration files. It is the name given with the
+// yaml tag or else the json tag, by default the lowercased field name as in yaml, and empty when the tag
+// is "-". Only the fields of the structs with the inline flag are inlined, like in yaml the embedded
+// structs without it are nested under the lowercased type name.
+func fileKey(sf reflect.StructField) (key string, inline bool) {
+	tag, ok := sf.Tag.Lookup("yaml")
+	if !ok {
+		tag = sf.Tag.Get("json")
+	}
+
+	opts := strings.Split(tag, ",")
+	name := opts[0]
+	if name == "-" {
+		return "", false
+	}
+
+	for _, o := range opts[1:] {
+		if o == "inline" {
+			inline = true
+		}
+	}
+	if name == "" {
+		name = strings.ToLower(sf.Name)
+	}
+
+	return name, inline
+}
+
+// inFile places the fields of the nested struct under its key in the configuration files, the fields
+// of a struct left out of the files are left out too.
+func inFile(fields []Field, key string, inline bool) {
+	if inline {
+		return
+	}
+
+	for i := range fields {
+		if key == "" || fields[i].path == nil {
+			fields[i].path = nil
+			continue
+		}
+		fields[i].path = append([]string{key}, fields[i].path...)
+	}
+}
+
 // createOrValidateEnvVarName validate env var that been given with a tag, if it is empty will generate default env var name from filed name.
 // It will return error if env var name is invalid.
 func createOrValidateEnvVarName(envVarTag string, filedKey []string) (string, error) {
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+)
+
+// file implements source interface for the values the parsers implementing TextParser leave to
+// the Loader. A nil file has no values.
+type file struct {
+	m map[string]string
+}
+
+// newFileSource returns a new source without values.
+func newFileSource() *file {
+	return &file{m: make(map[string]string)}
+}
+
+// set stores the value of the key at the path.
+func (s *file) set(path []string, value string) {
+	s.m[strings.Join(path, "\x00")] = value
+}
+
+func (s *file) Source(f Field) (string, bool) {
+	if s == nil || len(f.path) == 0 {
+		return "", false
+	}
+
+	val, ok := s.m[strings.Join(f.path, "\x00")]
+	return val, ok
+}
+
+// origin returns the key of the field in the configuration file.
+func (s *file) origin(f Field) string {
+	return "configuration file key " + strings.Join(f.path, ".")
+}
+
+// textUnmarshalerType is the type of the encoding.TextUnmarshaler interface.
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// isFileText reports whether the field of the type is decoded by the Loader from the text of its key in
+// the configuration files: complex numbers, byte slices and arrays, and the types decoded as a whole, like
+// url.URL, os.FileMode or the types registered with RegisterType, which the file formats can't decode. The
+// types implementing encoding.TextUnmarshaler are left to the parsers, they decode them from text too.
+func (l *Loader) isFileText(t reflect.Type) bool {
+	t = valueType(t)
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return true
+		}
+	}
+
+	return isLeaf(l.types, t)
+}
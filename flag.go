@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 )
 
 var (
@@ -13,110 +14,439 @@ var (
 	ErrVersion = errors.New("version requested")
 )
 
+// flagValue is a single occurrence of a flag on the command line.
 type flagValue struct {
+	Name     string
 	HasValue bool
 	Value    string
 }
 
-// flag implements the Parser interface for command line arguments.
+// flag implements the source interface for command line arguments.
 type flag struct {
-	args map[string]flagValue
+	values     []flagValue
+	positional []string
+
+	// options returns the settings of the field, the occurrences of slices and maps are joined
+	// with the delimiter. Default is Field.options.
+	options func(Field) fieldOptions
+}
+
+// newFlagParser returns a new source that can be used to process the conf struct with command line arguments.
+// The parser follows the POSIX/GNU conventions:
+//
+//   - long flags: --flag value, --flag=value
+//   - short flags: -f value, -fvalue, -f=value
+//   - bundled short boolean flags: -abc is the same as -a -b -c
+//   - "--" terminates the flags, all the arguments after it are positional
+//   - a flag that takes a value always consumes the next argument, so --offset -5 works
+//   - a negative number which is not a flag value is a positional argument
+//   - a boolean flag can be negated with the "no-" prefix, --no-flag is the same as --flag=false
+//
+// The fields are used to find out which flags are booleans and which ones take a value.
+// For compatibility, a long flag can also be given with a single dash, for example -port 8080.
+func (l *Loader) newFlagParser(args []string, fields []Field) (*flag, error) {
+	return l.parseFlags(args, fields, false)
 }
 
-// newFlagParser returns a new Parser that can be used to process the conf struct with command line arguments.
-func newFlagParser(args []string) (source, error) {
-	m := make(map[string]flagValue)
-	if len(args) > 0 {
-		for i := 0; i < len(args); i++ {
-			s := args[i]
+// parseFlags parses the command line arguments. When stop is true the parsing stops at the first
+// positional argument, which is kept in the positional arguments with all the arguments after it.
+// It is used to find the subcommand among the global flags.
+func (l *Loader) parseFlags(args []string, fields []Field, stop bool) (*flag, error) {
+	known := l.newFlagSpec(fields)
+
+	p := &flag{}
+	for i := 0; i < len(args); i++ {
+		s := args[i]
 
-			// if argument too short or doesn't start with a dash "-" then break
-			if len(s) < 2 || s[0] != '-' {
+		// everything after the terminator is a positional argument
+		if s == "--" {
+			p.positional = append(p.positional, args[i+1:]...)
+			break
+		}
+
+		// if argument too short, doesn't start with a dash "-" or it is a negative number
+		// then it is a positional argument
+		if len(s) < 2 || s[0] != '-' {
+			if stop {
+				p.positional = append(p.positional, args[i:]...)
 				break
 			}
+			p.positional = append(p.positional, s)
+			continue
+		}
 
-			minus := 1
-			// if the argument starts with two dashes "--" then increment minus by 1
-			if s[1] == '-' {
-				minus++
+		if isNumber(s) {
+			if _, ok := known.lookup(s[1:]); !ok {
+				if stop {
+					p.positional = append(p.positional, args[i:]...)
+					break
+				}
+				p.positional = append(p.positional, s)
+				continue
 			}
+		}
+
+		var (
+			next int
+			err  error
+		)
+		if s[1] == '-' || isLongFlag(s[1:], known) {
+			next, err = p.parseLong(s, args, i, known)
+		} else {
+			next, err = p.parseShort(s, args, i, known)
+		}
+		if err != nil {
+			return nil, err
+		}
+		i = next
+	}
+
+	return p, nil
+}
+
+// parseLong parses the long flag at args[i] and returns the index of the last consumed argument.
+func (p *flag) parseLong(s string, args []string, i int, known *flagSpec) (int, error) {
+	minus := 1
+	// if the argument starts with two dashes "--" then increment minus by 1
+	if s[1] == '-' {
+		minus++
+	}
+
+	// assign the flag name
+	name := s[minus:]
+	// check if name is not empty or starts with a dash "-" or starts with equal "="
+	if len(name) == 0 || name[0] == '-' || name[0] == '=' {
+		return i, fmt.Errorf("bad flag syntax: %s", s)
+	}
+
+	// if flag has a value after "=" sign then use it as value for the flag
+	// for example: --flag=value
+	hasValue := false
+	value := ""
+	for j := 0; j < len(name); j++ {
+		if name[j] == '=' {
+			value = name[j+1:]
+			name = name[:j]
+			hasValue = true
+			break
+		}
+	}
+
+	if err := builtinFlag(name); err != nil {
+		return i, err
+	}
+
+	// the hidden --completion=<shell> flag requests the completion script
+	if name == completionFlag {
+		if !hasValue && i+1 < len(args) {
+			value = args[i+1]
+		}
+		return i, &completionRequest{shell: value}
+	}
+
+	// the hidden --print-sample-config=<format> flag requests the sample configuration file
+	if name == sampleFlag {
+		if !hasValue && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			value = args[i+1]
+		}
+		return i, &sampleRequest{format: value}
+	}
+
+	// --no-flag is the negation of a boolean flag
+	if negated, ok := negatedFlag(name, known); ok {
+		if hasValue {
+			return i, fmt.Errorf("negated flag doesn't take a value: %s", s)
+		}
+
+		p.values = append(p.values, flagValue{Name: negated, HasValue: true, Value: "false"})
+		return i, nil
+	}
 
-			// assign the flag name
-			name := s[minus:]
-			// check if name is not empty or starts with a dash "-" or starts with equal "="
-			if len(name) == 0 || name[0] == '-' || name[0] == '=' {
-				return nil, fmt.Errorf("bad flag syntax: %s", s)
+	// if the flag still not have a value then use the next argument as value
+	// flag maybe in form of --flag value
+	if !hasValue {
+		isBool, ok := known.lookup(name)
+		switch {
+		case ok && !isBool:
+			if i+1 >= len(args) {
+				return i, fmt.Errorf("flag needs an argument: %s", s)
 			}
+			hasValue = true
+			value = args[i+1]
+			i++
+		case !ok && i+1 < len(args) && looksLikeValue(args[i+1]):
+			// unknown flag, keep the historical behavior and use the next argument
+			// as value unless it looks like a flag
+			hasValue = true
+			value = args[i+1]
+			i++
+		}
+	}
 
-			// if flag has a value after "=" sign then use it as value for the flag
-			// otherwise use the next argument as value
-			// for example: --flag=value or --flag value
-			hasValue := false
-			value := ""
-			for i := 0; i < len(name); i++ {
-				if name[i] == '=' {
-					value = name[i+1:]
-					name = name[:i]
-					hasValue = true
-					break
-				}
+	p.values = append(p.values, flagValue{
+		Name:     name,
+		HasValue: hasValue,
+		Value:    value,
+	})
+
+	return i, nil
+}
+
+// parseShort parses the short flag or the bundle of short flags at args[i] and returns
+// the index of the last consumed argument.
+func (p *flag) parseShort(s string, args []string, i int, known *flagSpec) (int, error) {
+	runes := []rune(s[1:])
+	if runes[0] == '=' {
+		return i, fmt.Errorf("bad flag syntax: %s", s)
+	}
+
+	for j := 0; j < len(runes); j++ {
+		name := string(runes[j])
+		if err := builtinFlag(name); err != nil {
+			return i, err
+		}
+
+		// the rest of the argument after the flag name, for example "8080" in -p8080
+		rest := string(runes[j+1:])
+
+		isBool, ok := known.lookup(name)
+		if !ok || isBool {
+			// -d=false form for booleans
+			if len(rest) > 0 && rest[0] == '=' {
+				p.values = append(p.values, flagValue{Name: name, HasValue: true, Value: rest[1:]})
+				return i, nil
 			}
 
-			if name == "help" || name == "h" {
-				return nil, ErrHelp
+			// a single unknown short flag keeps the historical behavior and uses the next
+			// argument as value unless it looks like a flag
+			if !ok && len(runes) == 1 && i+1 < len(args) && looksLikeValue(args[i+1]) {
+				p.values = append(p.values, flagValue{Name: name, HasValue: true, Value: args[i+1]})
+				return i + 1, nil
 			}
 
-			if name == "version" || name == "v" {
-				return nil, ErrVersion
+			p.values = append(p.values, flagValue{Name: name})
+			continue
+		}
+
+		// the flag takes a value, it is either the rest of the argument or the next argument
+		if len(rest) > 0 {
+			if rest[0] == '=' {
+				rest = rest[1:]
 			}
+			p.values = append(p.values, flagValue{Name: name, HasValue: true, Value: rest})
+			return i, nil
+		}
 
-			// if the flag still not have a value then use the next argument as value
-			// flag maybe in form of --flag value
-			if !hasValue && i+1 < len(args) {
-				if args[i+1][0] != '-' {
-					hasValue = true
-					value = args[i+1]
-					i++
-				}
+		if i+1 >= len(args) {
+			return i, fmt.Errorf("flag needs an argument: -%s", name)
+		}
+		p.values = append(p.values, flagValue{Name: name, HasValue: true, Value: args[i+1]})
+		return i + 1, nil
+	}
+
+	return i, nil
+}
+
+// flagSpec describes the known flags, it is used to find out which flags take a value.
+type flagSpec struct {
+	// flags are the known flags, the value is true when the flag is a boolean
+	flags map[string]bool
+
+	// collections are the flags of the elements of slices and maps of structs by the prefix
+	// of the collection, for example "upstreams." for --upstreams.0.host
+	collections map[string]*flagSpec
+}
+
+// newFlagSpec returns the flagSpec of the fields.
+func (l *Loader) newFlagSpec(fields []Field) *flagSpec {
+	s := &flagSpec{
+		flags:       make(map[string]bool),
+		collections: make(map[string]*flagSpec),
+	}
+
+	for _, f := range fields {
+		if f.Args {
+			continue
+		}
+		isBool := isBoolField(f.FieldValue)
+		s.flags[f.Flag] = isBool
+		if f.ShortFlag != 0 {
+			s.flags[string(f.ShortFlag)] = isBool
+		}
+
+		if isStructCollection(l.types, f.FieldValue.Type()) {
+			elemFields, err := l.elementFields(f.FieldValue.Type())
+			if err == nil {
+				s.collections[f.Flag+"."] = l.newFlagSpec(elemFields)
 			}
+		}
+	}
+
+	return s
+}
 
-			// store the key and value in the map
-			m[name] = flagValue{
-				HasValue: hasValue,
-				Value:    value,
+// lookup reports whether the flag is known and whether it is a boolean. The flags of
+// the elements of collections are matched with any index or key, for example
+// --upstreams.3.tls matches the tls flag of the Upstreams elements.
+func (s *flagSpec) lookup(name string) (isBool bool, ok bool) {
+	if isBool, ok := s.flags[name]; ok {
+		return isBool, true
+	}
+
+	for prefix, elem := range s.collections {
+		rest, found := strings.CutPrefix(name, prefix)
+		if !found {
+			continue
+		}
+		if _, sub, found := strings.Cut(rest, "."); found {
+			if isBool, ok := elem.lookup(sub); ok {
+				return isBool, true
 			}
 		}
 	}
 
-	return &flag{args: m}, nil
+	return false, false
 }
 
-// Source will return the value of the key if found.
+// Source will return the value of the key if found. When the flag is given more than once
+// the last occurrence wins, except for slices and maps where the occurrences accumulate,
+// so --tag a --tag b is the same as --tag a,b.
 func (f *flag) Source(field Field) (string, bool) {
-	var isBoolType = field.FieldValue.Kind() == reflect.Bool
+	isBool := isBoolField(field.FieldValue)
+	accumulate := isCollectionField(field.FieldValue)
+
+	var (
+		vals  []string
+		found bool
+	)
+	for _, v := range f.values {
+		if v.Name != field.Flag && (field.ShortFlag == 0 || v.Name != string(field.ShortFlag)) {
+			continue
+		}
+
+		found = true
+		val := v.Value
+		if !v.HasValue && isBool {
+			val = "true"
+		}
 
-	if field.ShortFlag != 0 {
-		if val, ok := f.source(string(field.ShortFlag), isBoolType); ok {
-			return val, true
+		if accumulate {
+			vals = append(vals, val)
+		} else {
+			vals = []string{val}
 		}
 	}
 
-	return f.source(field.Flag, isBoolType)
+	options := Field.options
+	if f.options != nil {
+		options = f.options
+	}
+
+	return strings.Join(vals, options(field).delimiter), found
+}
+
+// origin returns the name of the flag of the field.
+func (f *flag) origin(field Field) string {
+	return "flag " + flagString(field.Flag)
+}
+
+// Args returns the positional arguments.
+func (f *flag) Args() []string {
+	return f.positional
 }
 
-func (f *flag) source(key string, isBool bool) (string, bool) {
-	val, ok := f.args[key]
-	if !ok || !isBool {
-		return val.Value, ok
+// builtinFlag returns the error for the flags intercepted by the package itself.
+func builtinFlag(name string) error {
+	switch name {
+	case "help", "h":
+		return ErrHelp
+	case "version", "v":
+		return ErrVersion
 	}
 
-	if val.HasValue {
-		return val.Value, ok
+	return nil
+}
+
+// isLongFlag reports whether the argument given with a single dash is a known long flag,
+// for example -port or -port=8080.
+func isLongFlag(s string, known *flagSpec) bool {
+	for j := 0; j < len(s); j++ {
+		if s[j] == '=' {
+			s = s[:j]
+			break
+		}
+	}
+
+	if len([]rune(s)) < 2 {
+		return false
+	}
+
+	if _, ok := negatedFlag(s, known); ok {
+		return true
+	}
+
+	_, ok := known.lookup(s)
+	return ok
+}
+
+// negatedFlag reports whether the name is the "no-" negation of a known boolean flag
+// and returns the name of the flag. A flag explicitly named "no-something" takes precedence.
+func negatedFlag(name string, known *flagSpec) (string, bool) {
+	if _, ok := known.lookup(name); ok || !strings.HasPrefix(name, "no-") {
+		return "", false
+	}
+
+	isBool, ok := known.lookup(name[3:])
+	return name[3:], ok && isBool
+}
+
+// looksLikeValue reports whether the argument can be used as a value of an unknown flag.
+func looksLikeValue(s string) bool {
+	return len(s) == 0 || s[0] != '-' || isNumber(s)
+}
+
+// isNumber reports whether the string is a decimal number, for example -5 or -1.5. The other
+// forms accepted by strconv.ParseFloat, like -inf or -1e5, are bundled short flags.
+func isNumber(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	digits, dot := 0, false
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '.' && !dot:
+			dot = true
+		default:
+			return false
+		}
 	}
 
-	if !val.HasValue && isBool {
-		return "true", ok
+	return digits > 0
+}
+
+// isCollectionField reports whether the field is a slice, an array or a map, []byte and [N]byte are single values.
+func isCollectionField(v reflect.Value) bool {
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return t.Elem().Kind() != reflect.Uint8
+	case reflect.Map:
+		return true
+	}
+
+	return false
+}
+
+// isBoolField reports whether the field is a bool or a pointer to bool.
+func isBoolField(v reflect.Value) bool {
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
 	}
 
-	return "", false
+	return t.Kind() == reflect.Bool
 }
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"strings"
+)
+
+// formatJSON is the value of the format tag for the fields decoded as JSON.
+const formatJSON = "json"
+
+// isJSONLiteral reports whether the value is meant as a JSON literal for a field of the kind, a JSON
+// array for slices and arrays or a JSON object for maps and structs. The value is decoded as JSON even
+// when it's invalid, so the error reports the offset of the mistake. The values starting with a bracketed
+// IPv6 address, like [::1]:7000,[::1]:7001, are split with the delimiter.
+func isJSONLiteral(value string, kind reflect.Kind) bool {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return false
+	}
+
+	switch kind {
+	case reflect.Slice, reflect.Array:
+		return value[0] == '[' && !isBracketedAddr(value)
+	case reflect.Map, reflect.Struct:
+		return value[0] == '{'
+	}
+
+	return false
+}
+
+// isBracketedAddr reports whether the value starts with an IPv6 address in brackets, like [::1]:7000.
+func isBracketedAddr(value string) bool {
+	end := strings.IndexByte(value, ']')
+	if end < 0 {
+		return false
+	}
+
+	_, err := netip.ParseAddr(value[1:end])
+	return err == nil
+}
+
+// processJSON decodes the JSON value into the field, the value replaces the current value of the field.
+func processJSON(value string, field reflect.Value) error {
+	v := reflect.New(field.Type())
+	if err := json.Unmarshal([]byte(value), v.Interface()); err != nil {
+		return jsonError(err)
+	}
+
+	field.Set(v.Elem())
+	return nil
+}
+
+// unmarshalJSON decodes the value with the json.Unmarshaler. The JSON strings, objects and arrays are
+// passed as they are, the plain values are passed as JSON strings, so a type backed by a string decodes
+// 123 or true like any other text. A JSON number or boolean is passed as it is first, for the types
+// decoding them, and as a JSON string when the type rejects it.
+func unmarshalJSON(u json.Unmarshaler, value string) error {
+	var err error
+	if v := strings.TrimSpace(value); v != "" && json.Valid([]byte(v)) {
+		switch v[0] {
+		case '"', '{', '[':
+			return u.UnmarshalJSON([]byte(v))
+		case 'n':
+			// null would leave the value unset, it is the text null
+		default:
+			if err = u.UnmarshalJSON([]byte(v)); err == nil {
+				return nil
+			}
+		}
+	}
+
+	b, mErr := json.Marshal(value)
+	if mErr != nil {
+		return mErr
+	}
+	if qErr := u.UnmarshalJSON(b); qErr != nil {
+		// the error of the value as it is, when the type rejects both
+		if err != nil {
+			return err
+		}
+		return qErr
+	}
+
+	return nil
+}
+
+// jsonError returns the error of the JSON decoding with the offset of the error in the value.
+func jsonError(err error) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("invalid JSON at offset %d: %w", syntaxErr.Offset, err)
+	case errors.As(err, &typeErr):
+		return fmt.Errorf("invalid JSON at offset %d: %w", typeErr.Offset, err)
+	}
+
+	return fmt.Errorf("invalid JSON: %w", err)
+}
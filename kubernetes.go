@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KubernetesManifests generates the Kubernetes ConfigMap of the fields which are not masked, with their
+// current values, and the Secret stub of the masked fields to fill in. Both are named after the given name
+// and separated with "---". The usage and the validation rules of the fields are written as comments and
+// the required fields without a value are listed commented out in the ConfigMap, as an empty value can't
+// be decoded for every type. The keys of the Secret stub are commented out too, an existing key, even
+// empty, is injected into the container and overrides the default of the field. The Secret is left out
+// when no field is masked.
+//
+// The options of the Loader, like WithDelimiter, apply to the values.
+func KubernetesManifests(cfg interface{}, name string, opts ...Option) (string, error) {
+	l := NewLoader(opts...)
+	fields, _, err := l.currentFields(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("apiVersion: v1\nkind: ConfigMap\nmetadata:\n")
+	fmt.Fprintf(&sb, "  name: %s\n", name)
+	sb.WriteString("data:\n")
+
+	var secrets []Field
+	for _, f := range fields {
+		if f.Args {
+			continue
+		}
+		if f.Mask {
+			secrets = append(secrets, f)
+			continue
+		}
+
+		if comment := sampleComment(f); comment != "" {
+			fmt.Fprintf(&sb, "  # %s\n", comment)
+		}
+
+		value := valueToString(f.FieldValue, l.options(f))
+		if value == "" && f.Required {
+			fmt.Fprintf(&sb, "  # %s: \"\"\n", f.EnvVar)
+			continue
+		}
+		if value == "" {
+			continue
+		}
+
+		b, err := marshalJSON(value)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "  %s: %s\n", f.EnvVar, b)
+	}
+
+	if len(secrets) == 0 {
+		return sb.String(), nil
+	}
+
+	sb.WriteString("---\napiVersion: v1\nkind: Secret\nmetadata:\n")
+	fmt.Fprintf(&sb, "  name: %s\n", name)
+	sb.WriteString("type: Opaque\nstringData:\n")
+	for _, f := range secrets {
+		if comment := sampleComment(f); comment != "" {
+			fmt.Fprintf(&sb, "  # %s\n", comment)
+		}
+		fmt.Fprintf(&sb, "  # %s: \"\"\n", f.EnvVar)
+	}
+
+	return sb.String(), nil
+}
+
+// KubernetesEnv generates the env and envFrom block of the container of a Deployment using the manifests
+// generated by KubernetesManifests with the same name. The ConfigMap is referenced as a whole with envFrom,
+// the masked fields are referenced one by one from the Secret and so are the required fields from the
+// ConfigMap. The references of the required fields are flagged with a comment and are not optional, so the
+// container doesn't start without them.
+func KubernetesEnv(cfg interface{}, name string, opts ...Option) (string, error) {
+	l := NewLoader(opts...)
+	fields, _, err := l.currentFields(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("envFrom:\n  - configMapRef:\n")
+	fmt.Fprintf(&sb, "      name: %s\n", name)
+
+	var refs []Field
+	for _, f := range fields {
+		if (f.Mask || f.Required) && !f.Args {
+			refs = append(refs, f)
+		}
+	}
+	if len(refs) == 0 {
+		return sb.String(), nil
+	}
+
+	sb.WriteString("env:\n")
+	for _, f := range refs {
+		ref := "configMapKeyRef"
+		if f.Mask {
+			ref = "secretKeyRef"
+		}
+
+		if f.Required {
+			sb.WriteString("  # required\n")
+		}
+		fmt.Fprintf(&sb, "  - name: %s\n", f.EnvVar)
+		fmt.Fprintf(&sb, "    valueFrom:\n      %s:\n", ref)
+		fmt.Fprintf(&sb, "        name: %s\n", name)
+		fmt.Fprintf(&sb, "        key: %s\n", f.EnvVar)
+		if !f.Required {
+			sb.WriteString("        optional: true\n")
+		}
+	}
+
+	return sb.String(), nil
+}
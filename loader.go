@@ -0,0 +1,273 @@
+package config
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// Loader loads the configuration struct from the parsers, environment variables and command line flags.
+// Process and ProcessWithParser use a Loader with the default options.
+type Loader struct {
+	parsers   []Parser
+	mutators  []MutatorFunc
+	args      []string
+	strict    bool
+	envPrefix string
+	delimiter string
+	separator string
+	output    io.Writer
+	types     typeRegistry
+
+	// the environment variable name patterns of the fields masked automatically
+	maskPatterns []string
+
+	// the settings of the Watcher
+	watchFiles    []string
+	watchInterval time.Duration
+	reloadSignal  bool
+	reloadError   func(error)
+	rejectStatic  bool
+
+	// the masking of the exported configuration, nil uses the mode of each field
+	exportMask *MaskMode
+
+	// the settings of the usage message
+	programName   string
+	description   string
+	usageHeader   string
+	usageFooter   string
+	examples      []string
+	usageTemplate string
+	wrapWidth     int
+}
+
+// Option configures the Loader.
+type Option func(l *Loader)
+
+// NewLoader returns a new Loader configured with the given options. By default, the command line
+// arguments are taken from os.Args.
+func NewLoader(opts ...Option) *Loader {
+	l := &Loader{output: os.Stdout, maskPatterns: defaultMaskPatterns}
+	if len(os.Args) > 1 {
+		l.args = os.Args[1:]
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// WithParsers sets the parsers that are executed before the environment variables and command line flags.
+func WithParsers(parsers ...Parser) Option {
+	return func(l *Loader) {
+		l.parsers = append(l.parsers, parsers...)
+	}
+}
+
+// WithMutators sets the mutators executed before the value from a source is set to the field.
+func WithMutators(mutators ...MutatorFunc) Option {
+	return func(l *Loader) {
+		l.mutators = append(l.mutators, mutators...)
+	}
+}
+
+// WithArgs sets the command line arguments, without the program name, used instead of os.Args.
+func WithArgs(args []string) Option {
+	return func(l *Loader) {
+		l.args = args
+	}
+}
+
+// WithStrict turns on the strict mode. In strict mode any flag that doesn't match a field or a built-in
+// flag is rejected, and so is any environment variable starting with the prefix set by WithEnvPrefix
+// that doesn't map to a field. The error suggests the closest known names.
+func WithStrict() Option {
+	return func(l *Loader) {
+		l.strict = true
+	}
+}
+
+// WithEnvPrefix sets the prefix of the application environment variables, for example "APP".
+// It is used by the strict mode to find the environment variables which don't map to any field.
+func WithEnvPrefix(prefix string) Option {
+	return func(l *Loader) {
+		l.envPrefix = prefix
+	}
+}
+
+// WithDelimiter sets the delimiter of the slice and map values of the fields without the delim tag.
+// Default is ",".
+func WithDelimiter(delim string) Option {
+	return func(l *Loader) {
+		l.delimiter = delim
+	}
+}
+
+// WithSeparator sets the separator of the map keys and values of the fields without the kvsep tag.
+// Default is ":".
+func WithSeparator(sep string) Option {
+	return func(l *Loader) {
+		l.separator = sep
+	}
+}
+
+// WithOutput sets the writer of the output requested with the built-in flags, for example the
+// completion script printed by --completion=<shell> or the sample configuration file printed by
+// --print-sample-config=<format>. Default is os.Stdout.
+func WithOutput(w io.Writer) Option {
+	return func(l *Loader) {
+		l.output = w
+	}
+}
+
+// WithWatchFiles sets the files watched by the Watcher, a change of any of them reloads the configuration.
+func WithWatchFiles(paths ...string) Option {
+	return func(l *Loader) {
+		l.watchFiles = append(l.watchFiles, paths...)
+	}
+}
+
+// WithWatchInterval sets the interval of polling the watched files. Default is one second.
+func WithWatchInterval(d time.Duration) Option {
+	return func(l *Loader) {
+		l.watchInterval = d
+	}
+}
+
+// WithReloadSignal makes the Watcher reload the configuration when the process receives SIGHUP.
+func WithReloadSignal() Option {
+	return func(l *Loader) {
+		l.reloadSignal = true
+	}
+}
+
+// WithReloadError sets the function called with the errors of the reloads done by the Watcher,
+// for example to log the invalid configuration which is not applied.
+func WithReloadError(fn func(error)) Option {
+	return func(l *Loader) {
+		l.reloadError = fn
+	}
+}
+
+// WithRejectStaticChanges makes the Watcher reject the whole reload when a static field, tagged with
+// reload:"false", has changed. By default the other fields are applied and the static fields keep
+// their values until the restart.
+func WithRejectStaticChanges() Option {
+	return func(l *Loader) {
+		l.rejectStatic = true
+	}
+}
+
+// WithMaskPatterns replaces the environment variable name patterns, in path.Match syntax, of the string
+// and byte slice fields masked automatically with MaskFull, for example "*_API_KEY". The fields with the
+// mask tag keep their mode. WithMaskPatterns() without patterns turns off the automatic masking. Default
+// patterns match the names ending with PASSWORD, PASSWD, TOKEN, SECRET, SECRET_KEY, CREDENTIALS and
+// PRIVATE_KEY, for example DB_PASSWORD or GITHUB_TOKEN.
+func WithMaskPatterns(patterns ...string) Option {
+	return func(l *Loader) {
+		l.maskPatterns = patterns
+	}
+}
+
+// WithExportMask sets the mask mode of the masked fields in the configuration written by Export and
+// ExportArgs, MaskNone writes their values as is. By default each field is masked with its own mode.
+func WithExportMask(mode MaskMode) Option {
+	return func(l *Loader) {
+		l.exportMask = &mode
+	}
+}
+
+// WithProgramName sets the name of the program in the usage message. Default is os.Args[0].
+func WithProgramName(name string) Option {
+	return func(l *Loader) {
+		l.programName = name
+	}
+}
+
+// WithDescription sets the description of the application in the usage message.
+func WithDescription(description string) Option {
+	return func(l *Loader) {
+		l.description = description
+	}
+}
+
+// WithUsageHeader sets the text written before the usage message, for example the banner of the application.
+func WithUsageHeader(header string) Option {
+	return func(l *Loader) {
+		l.usageHeader = header
+	}
+}
+
+// WithUsageFooter sets the text written after the usage message, for example the link to the documentation.
+func WithUsageFooter(footer string) Option {
+	return func(l *Loader) {
+		l.usageFooter = footer
+	}
+}
+
+// WithExamples sets the example command lines listed in the usage message.
+func WithExamples(examples ...string) Option {
+	return func(l *Loader) {
+		l.examples = append(l.examples, examples...)
+	}
+}
+
+// WithUsageTemplate sets the text/template of the usage message. The template is executed with the
+// AppName, Command, Description, Header, Footer, Examples, Field, Groups, Global and Commands of the
+// message and can use the formatFlag, formatFieldType and formatField functions.
+func WithUsageTemplate(tmpl string) Option {
+	return func(l *Loader) {
+		l.usageTemplate = tmpl
+	}
+}
+
+// WithWrapWidth sets the width the lines of the usage message are wrapped at, the descriptions of
+// the options are wrapped in their column. Default is the COLUMNS environment variable, the lines
+// are not wrapped when it is not set. A negative width turns off the wrapping.
+func WithWrapWidth(width int) Option {
+	return func(l *Loader) {
+		l.wrapWidth = width
+	}
+}
+
+// Load loads the configuration into cfg, which must be a non-nil pointer to a struct. The parsers
+// are executed first, then the values from environment variables and command line flags are applied.
+func (l *Loader) Load(cfg interface{}) error {
+	// process the struct with the given parsers
+	file, err := l.parse(cfg)
+	if err != nil {
+		return err
+	}
+
+	fields, err := l.extractFields(nil, cfg)
+	if err != nil {
+		return err
+	}
+
+	err = l.parseWithDefaultSource(l.args, fields, file)
+	err = l.sampleConfig(err, fields)
+	return completionScript(err, fields, nil, l.write)
+}
+
+// options returns the settings used to decode the value of the field.
+func (l *Loader) options(f Field) fieldOptions {
+	opts := f.options()
+	if f.Delimiter == "" && l.delimiter != "" {
+		opts.delimiter = l.delimiter
+	}
+	if f.Separator == "" && l.separator != "" {
+		opts.separator = l.separator
+	}
+	opts.types = l.types
+
+	return opts
+}
+
+// write writes the string to the output of the Loader.
+func (l *Loader) write(s string) error {
+	_, err := io.WriteString(l.output, s)
+	return err
+}
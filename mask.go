@@ -0,0 +1,132 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"path"
+	"reflect"
+	"strings"
+)
+
+// MaskMode declares how a sensitive value is rendered in the output.
+type MaskMode string
+
+const (
+	// MaskNone leaves the value as is.
+	MaskNone MaskMode = ""
+	// MaskFull replaces the whole value with a fixed placeholder, so even the length is hidden.
+	MaskFull MaskMode = "full"
+	// MaskLast4 shows only the last four characters of the value.
+	MaskLast4 MaskMode = "last4"
+	// MaskFirst2 shows only the first two characters of the value.
+	MaskFirst2 MaskMode = "first2"
+	// MaskHash replaces the value with a stable SHA-256 prefix, so operators can
+	// compare values across hosts without seeing them.
+	MaskHash MaskMode = "hash"
+	// MaskLength replaces every character of the value with "*", so only the length is visible.
+	MaskLength MaskMode = "length"
+)
+
+// maskPlaceholder is the value shown for fully masked fields.
+const maskPlaceholder = "********"
+
+// defaultMaskPatterns are the environment variable name patterns, in path.Match syntax, of the
+// string and byte slice fields that are masked automatically with MaskFull even without a mask tag.
+// The patterns match the last segments of the name, so DB_PASSWORD is masked but PASSWORD_MIN_LENGTH
+// is not. Use WithMaskPatterns to replace them, or `mask:"false"` on a single field.
+var defaultMaskPatterns = []string{
+	"PASSWORD", "*_PASSWORD",
+	"PASSWD", "*_PASSWD",
+	"TOKEN", "*_TOKEN",
+	"SECRET", "*_SECRET",
+	"SECRET_KEY", "*_SECRET_KEY",
+	"CREDENTIALS", "*_CREDENTIALS",
+	"PRIVATE_KEY", "*_PRIVATE_KEY",
+}
+
+// parseMaskMode parses the value of the mask tag. Empty tag falls back to the patterns matched
+// against the environment variable name of the string and byte slice fields, the other types
+// hold settings like TOKEN_TTL rather than secrets.
+func parseMaskMode(tag, envVar string, t reflect.Type, patterns []string) (MaskMode, error) {
+	switch tag {
+	case "":
+		if !isSecretType(t) {
+			return MaskNone, nil
+		}
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, envVar); ok {
+				return MaskFull, nil
+			}
+		}
+		return MaskNone, nil
+	case "false":
+		return MaskNone, nil
+	case "true":
+		return MaskFull, nil
+	}
+
+	switch m := MaskMode(tag); m {
+	case MaskFull, MaskLast4, MaskFirst2, MaskHash, MaskLength:
+		return m, nil
+	}
+
+	return MaskNone, errors.New("invalid mask mode has been provided: " + tag)
+}
+
+// isSecretType reports whether the type can hold a secret masked automatically: a string or
+// a byte slice, or a pointer to one of them.
+func isSecretType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	}
+
+	return false
+}
+
+// maskString masks the string according to the given mode. Empty values are returned as is,
+// there is nothing to leak and it is useful to see that a secret is not set.
+func maskString(s string, mode MaskMode) string {
+	if s == "" {
+		return s
+	}
+
+	switch mode {
+	case MaskFull:
+		return maskPlaceholder
+	case MaskLast4:
+		return maskReveal(s, 4, false)
+	case MaskFirst2:
+		return maskReveal(s, 2, true)
+	case MaskHash:
+		sum := sha256.Sum256([]byte(s))
+		return "sha256:" + hex.EncodeToString(sum[:])[:12]
+	case MaskLength:
+		return strings.Repeat("*", len([]rune(s)))
+	}
+
+	return s
+}
+
+// maskReveal shows n characters from the start or the end of the string and masks the rest.
+// Values shorter than twice the revealed part are masked fully, otherwise short tokens and
+// PINs would be mostly visible.
+func maskReveal(s string, n int, first bool) string {
+	r := []rune(s)
+	if len(r) < 2*n {
+		return maskPlaceholder
+	}
+
+	if first {
+		return string(r[:n]) + strings.Repeat("*", len(r)-n)
+	}
+
+	return strings.Repeat("*", len(r)-n) + string(r[len(r)-n:])
+}
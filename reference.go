@@ -0,0 +1,185 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fieldGroup is the group of fields of a nested struct in the reference documentation.
+type fieldGroup struct {
+	Name   string
+	Fields []Field
+}
+
+// Markdown generates the reference documentation of the configuration in Markdown. Every field is a
+// row of a table with its environment variable, flags, type, default, validation rules and usage.
+// The fields of nested structs are listed in their own sections.
+func Markdown(cfg interface{}) (string, error) {
+	l := NewLoader()
+	fields, err := l.extractFields(nil, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s configuration\n", programName())
+
+	for _, g := range groupFields(l.optionFields(fields)) {
+		if g.Name != "" {
+			fmt.Fprintf(&sb, "\n## %s\n", g.Name)
+		}
+
+		sb.WriteString("\n| Environment | Flag | Short | Type | Default | Required | Validation | Description |\n")
+		sb.WriteString("|-------------|------|-------|------|---------|----------|------------|-------------|\n")
+		for _, f := range g.Fields {
+			short := ""
+			if f.ShortFlag != 0 {
+				short = "`-" + string(f.ShortFlag) + "`"
+			}
+			def := ""
+			if f.Default != "" {
+				def = "`" + f.Default + "`"
+			}
+			required := ""
+			if f.Required {
+				required = "yes"
+			}
+
+			description := f.Usage
+			if d := deprecation(f); d != "" {
+				description = strings.TrimSpace("**" + d + "** " + description)
+			}
+
+			cells := []string{
+				"`" + f.EnvVar + "`", "`" + formatFlag(f) + "`", short, formatFieldType(f.FieldValue),
+				def, required, validation(f), description,
+			}
+			for i := range cells {
+				cells[i] = strings.ReplaceAll(cells[i], "|", `\|`)
+			}
+			fmt.Fprintf(&sb, "| %s |\n", strings.Join(cells, " | "))
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// ManPage generates the man page of the application in the roff format, the fields are listed in
+// the OPTIONS section and the fields of nested structs in their own subsections. The version is
+// shown in the footer of the page.
+func ManPage(cfg interface{}, version string) (string, error) {
+	l := NewLoader()
+	fields, err := l.extractFields(nil, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	name := programName()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, ".TH %q 1 \"\" %q \"User Commands\"\n", strings.ToUpper(name), strings.TrimSpace(name+" "+version))
+	sb.WriteString(".SH NAME\n")
+	fmt.Fprintf(&sb, "%s \\- configuration reference\n", roffEscape(name))
+	sb.WriteString(".SH SYNOPSIS\n")
+	fmt.Fprintf(&sb, ".B %s\n[\\fIOPTIONS\\fR]\n", roffEscape(name))
+	sb.WriteString(".SH DESCRIPTION\n")
+	sb.WriteString(roffEscape(defaultDescription) + "\n")
+	sb.WriteString(".SH OPTIONS\n")
+
+	for _, g := range groupFields(l.optionFields(fields)) {
+		if g.Name != "" {
+			fmt.Fprintf(&sb, ".SS %s\n", roffEscape(g.Name))
+		}
+
+		for _, f := range g.Fields {
+			sb.WriteString(".TP\n")
+			if f.ShortFlag != 0 {
+				fmt.Fprintf(&sb, "\\fB%s\\fR, ", roffEscape("-"+string(f.ShortFlag)))
+			}
+			fmt.Fprintf(&sb, "\\fB%s\\fR \\fI%s\\fR\n", roffEscape(formatFlag(f)), roffEscape(formatFieldType(f.FieldValue)))
+
+			if f.Usage != "" {
+				sb.WriteString(roffEscape(f.Usage) + "\n.br\n")
+			}
+
+			details := []string{"Environment: \\fB" + roffEscape(f.EnvVar) + "\\fR."}
+			if f.Default != "" {
+				details = append(details, "Default: "+roffEscape(f.Default)+".")
+			}
+			if f.Required {
+				details = append(details, "Required.")
+			}
+			if v := validation(f); v != "" {
+				details = append(details, "Validation: "+roffEscape(v)+".")
+			}
+			if d := deprecation(f); d != "" {
+				details = append(details, roffEscape(d))
+			}
+			sb.WriteString(strings.Join(details, " ") + "\n")
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// groupFields groups the fields by the nested struct they belong to, in the order of the first
+// field of each group. The top level fields come first.
+func groupFields(fields []Field) []fieldGroup {
+	groups := []fieldGroup{{}}
+	index := map[string]int{"": 0}
+	for _, f := range fields {
+		i, ok := index[f.Group]
+		if !ok {
+			i = len(groups)
+			index[f.Group] = i
+			groups = append(groups, fieldGroup{Name: f.Group})
+		}
+		groups[i].Fields = append(groups[i].Fields, f)
+	}
+
+	if len(groups[0].Fields) == 0 {
+		groups = groups[1:]
+	}
+
+	return groups
+}
+
+// validation describes the validation rules of the field.
+func validation(f Field) string {
+	var rules []string
+	if len(f.OneOf) > 0 {
+		rules = append(rules, "one of: "+strings.Join(f.OneOf, ", "))
+	}
+	if f.Min != "" {
+		rules = append(rules, "min: "+f.Min)
+	}
+	if f.Max != "" {
+		rules = append(rules, "max: "+f.Max)
+	}
+
+	return strings.Join(rules, "; ")
+}
+
+// deprecation describes the deprecation of the field, the deprecated tag is either true
+// or the deprecation message.
+func deprecation(f Field) string {
+	switch f.Deprecated {
+	case "", "false":
+		return ""
+	case "true":
+		return "Deprecated."
+	}
+
+	return "Deprecated: " + strings.TrimSuffix(f.Deprecated, ".") + "."
+}
+
+// roffEscape escapes the text for the roff format.
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+
+	return s
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// ErrRestartRequired is returned by the reload of a Watcher when a static field, tagged with
+// reload:"false", has changed. The static fields keep their values until the restart.
+var ErrRestartRequired = errors.New("restart required")
+
+// RestartError is the error of the reload which changes static fields. It matches ErrRestartRequired.
+type RestartError struct {
+	// Changes are the changes of the static fields.
+	Changes []Change
+}
+
+// Error implements the error interface.
+func (e *RestartError) Error() string {
+	flags := make([]string, 0, len(e.Changes))
+	for _, c := range e.Changes {
+		flags = append(flags, flagString(c.Flag))
+	}
+
+	return ErrRestartRequired.Error() + ": static fields changed: " + strings.Join(flags, ", ")
+}
+
+// Is reports whether the target is ErrRestartRequired.
+func (e *RestartError) Is(target error) bool {
+	return target == ErrRestartRequired
+}
+
+// markStatic marks the fields as static when their parent is static.
+func markStatic(fields []Field, static bool) {
+	if !static {
+		return
+	}
+
+	for i := range fields {
+		fields[i].Static = true
+	}
+}
+
+// keepStatic sets the static fields of the new configuration to their old values. A static section
+// or collection of structs is kept as a whole, so setting or clearing the section and adding or
+// removing the elements are not applied either.
+func (l *Loader) keepStatic(old, new interface{}) error {
+	o, n := reflect.ValueOf(old), reflect.ValueOf(new)
+	if o.Kind() != reflect.Ptr || o.IsNil() || n.Kind() != reflect.Ptr || n.IsNil() || o.Type() != n.Type() {
+		return ErrInvalidTarget
+	}
+	if n.Elem().Kind() != reflect.Struct {
+		return ErrInvalidTarget
+	}
+
+	l.keepStaticFields(o.Elem(), n.Elem())
+	return nil
+}
+
+// keepStaticFields sets the static fields of the new struct to the values of the old one and
+// drills down through the nested structs, sections and collections of structs.
+func (l *Loader) keepStaticFields(old, new reflect.Value) {
+	t := new.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" || sf.Tag.Get(envVarTag) == "-" {
+			continue
+		}
+
+		if sf.Tag.Get(reloadTag) == "false" {
+			new.Field(i).Set(old.Field(i))
+			continue
+		}
+
+		if sf.Tag.Get(formatTag) != formatJSON {
+			l.keepStaticValue(old.Field(i), new.Field(i))
+		}
+	}
+}
+
+// keepStaticValue keeps the static fields nested in the value. The fields of a section which is
+// set or cleared by the reload are compared with the zero struct, the section cleared by the reload
+// is allocated again when its static fields hold values. The elements of the collections are matched
+// by their index or key, the added elements have no old values to keep.
+func (l *Loader) keepStaticValue(old, new reflect.Value) {
+	t := new.Type()
+	switch {
+	case t.Kind() == reflect.Struct && !isLeaf(l.types, t):
+		l.keepStaticFields(old, new)
+
+	case isSection(l.types, t):
+		switch {
+		case !old.IsNil() && !new.IsNil():
+			l.keepStaticFields(old.Elem(), new.Elem())
+		case !new.IsNil():
+			l.keepStaticFields(reflect.New(t.Elem()).Elem(), new.Elem())
+		case !old.IsNil():
+			v := reflect.New(t.Elem())
+			l.keepStaticFields(old.Elem(), v.Elem())
+			if !v.Elem().IsZero() {
+				new.Set(v)
+			}
+		}
+
+	case isStructCollection(l.types, t) && t.Kind() == reflect.Slice:
+		for i := 0; i < old.Len() && i < new.Len(); i++ {
+			l.keepStaticValue(old.Index(i), new.Index(i))
+		}
+
+	case isStructCollection(l.types, t) && t.Kind() == reflect.Map:
+		iter := new.MapRange()
+		for iter.Next() {
+			ov := old.MapIndex(iter.Key())
+			if !ov.IsValid() {
+				continue
+			}
+			// the map elements are not addressable, the element is updated in a copy
+			v := reflect.New(t.Elem()).Elem()
+			v.Set(iter.Value())
+			l.keepStaticValue(ov, v)
+			new.SetMapIndex(iter.Key(), v)
+		}
+	}
+}
@@ -0,0 +1,442 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrSampleConfig is returned when the sample configuration file is requested with the hidden
+// --print-sample-config flag. The sample is written to the output of the Loader before the error is returned.
+var ErrSampleConfig = errors.New("sample config requested")
+
+// sampleRequest is returned by the flag parser when --print-sample-config=<format> is set.
+type sampleRequest struct {
+	format string
+}
+
+func (s *sampleRequest) Error() string {
+	return ErrSampleConfig.Error() + ": " + s.format
+}
+
+func (s *sampleRequest) Is(target error) bool {
+	return target == ErrSampleConfig
+}
+
+// sampleFlag is the name of the hidden built-in flag printing the sample configuration file.
+const sampleFlag = "print-sample-config"
+
+// secretPlaceholder is the value of the masked fields in the sample configuration file.
+const secretPlaceholder = "<secret>"
+
+// sampleNode is a field or a nested struct of the sample configuration file.
+type sampleNode struct {
+	name     string
+	field    *Field
+	children []*sampleNode
+}
+
+// child returns the nested struct with the name, it is added when it is missing.
+func (n *sampleNode) child(name string) *sampleNode {
+	for _, c := range n.children {
+		if c.field == nil && c.name == name {
+			return c
+		}
+	}
+
+	c := &sampleNode{name: name}
+	n.children = append(n.children, c)
+
+	return c
+}
+
+// Sample generates the sample configuration file in the given format: yaml, json, toml or env. The fields
+// have their default values, or the zero values when they have none, and the masked strings a placeholder.
+// The lines of the other masked fields and of the values which can't be loaded back are commented out,
+// so the sample loads back. The usage, the required flag and the validation rules of the fields are
+// written as comments, except in json which has none. The keys are named after the yaml or json tags,
+// the env format lists the environment variables.
+func Sample(cfg interface{}, format string) (string, error) {
+	l := NewLoader()
+	fields, err := l.extractFields(nil, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	return l.sample(fields, format)
+}
+
+// sample generates the sample configuration file of the fields.
+func (l *Loader) sample(fields []Field, format string) (string, error) {
+	var sb strings.Builder
+	var err error
+
+	switch format {
+	case "yaml", "yml":
+		fmt.Fprintf(&sb, "# %s configuration\n", programName())
+		err = l.yamlSample(&sb, sampleTree(fields), "")
+	case "toml":
+		fmt.Fprintf(&sb, "# %s configuration\n", programName())
+		err = l.tomlSample(&sb, sampleTree(fields), nil)
+	case "env":
+		fmt.Fprintf(&sb, "# %s configuration\n", programName())
+		err = l.envSample(&sb, fields, false)
+	case "json":
+		var v interface{}
+		v, err = l.jsonSample(sampleTree(fields))
+		if err == nil {
+			enc := json.NewEncoder(&sb)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(v)
+		}
+	default:
+		return "", errors.New("unsupported sample format: " + format)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+// sampleConfig writes the sample configuration file in the format of the request to the output of the
+// Loader when the error is a sample request, and returns ErrSampleConfig. Other errors are returned as
+// is. The default format is yaml.
+func (l *Loader) sampleConfig(err error, fields []Field) error {
+	var req *sampleRequest
+	if !errors.As(err, &req) {
+		return err
+	}
+
+	format := req.format
+	if format == "" {
+		format = "yaml"
+	}
+
+	s, sErr := l.sample(fields, format)
+	if sErr != nil {
+		return sErr
+	}
+
+	if wErr := l.write(s); wErr != nil {
+		return wErr
+	}
+
+	return ErrSampleConfig
+}
+
+// sampleTree places the fields read from the configuration files in the tree of the nested structs.
+func sampleTree(fields []Field) *sampleNode {
+	root := &sampleNode{}
+	for i := range fields {
+		f := &fields[i]
+		if f.Args || len(f.path) == 0 {
+			continue
+		}
+
+		n := root
+		for _, name := range f.path[:len(f.path)-1] {
+			n = n.child(name)
+		}
+		n.children = append(n.children, &sampleNode{name: f.path[len(f.path)-1], field: f})
+	}
+
+	return root
+}
+
+// sampleValue returns the value of the field in the sample as a JSON value: the default value or
+// the zero value of the type, and the placeholder for the masked strings without a default. It reports
+// whether the line of the field is commented out, so the sample loads back: the other masked fields
+// keep their defaults in the comment and so do the values which can't be decoded, like the empty
+// time.Time.
+func (l *Loader) sampleValue(f Field) (interface{}, bool, error) {
+	t := valueType(f.FieldValue.Type())
+	if t.Kind() == reflect.Interface {
+		return nil, false, nil
+	}
+
+	if f.Mask && f.Default == "" && t.Kind() == reflect.String {
+		return secretPlaceholder, false, nil
+	}
+
+	var v interface{}
+	if f.Default != "" {
+		var err error
+		v, err = schemaValue(f.Default, t, l.options(f))
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid default value for field %s: %w", f.Name, err)
+		}
+	} else {
+		v = jsonValue(reflect.New(t).Elem(), l.options(f))
+	}
+
+	return v, f.Mask || !l.fileDecodes(f, v), nil
+}
+
+// fileDecodes reports whether the JSON value of the field loads back from the yaml and json files. The
+// values the yaml parser leaves to the Loader are decoded by the Loader, the others by the yaml decoder.
+func (l *Loader) fileDecodes(f Field, v interface{}) bool {
+	t := f.FieldValue.Type()
+	if s, ok := v.(string); ok && l.isFileText(t) {
+		return l.decodes(f, s)
+	}
+
+	b, err := marshalJSON(v)
+	if err != nil {
+		return false
+	}
+
+	return yaml.Unmarshal(b, reflect.New(t).Interface()) == nil
+}
+
+// decodes reports whether the value in the format of the tags decodes into the field.
+func (l *Loader) decodes(f Field, value string) bool {
+	return processField(value, reflect.New(f.FieldValue.Type()).Elem(), l.options(f)) == nil
+}
+
+// sampleComment returns the comment of the field: the usage followed by the required flag, the
+// validation rules and the deprecation.
+func sampleComment(f Field) string {
+	var notes []string
+	if f.Required {
+		notes = append(notes, "required")
+	}
+	if v := validation(f); v != "" {
+		notes = append(notes, v)
+	}
+	if d := deprecation(f); d != "" {
+		notes = append(notes, d)
+	}
+
+	switch {
+	case len(notes) == 0:
+		return f.Usage
+	case f.Usage == "":
+		return strings.Join(notes, "; ")
+	}
+
+	return f.Usage + " (" + strings.Join(notes, "; ") + ")"
+}
+
+// yamlSample writes the fields of the node in yaml, the values are written in the JSON flow style.
+func (l *Loader) yamlSample(sb *strings.Builder, n *sampleNode, indent string) error {
+	for _, c := range n.children {
+		if c.field == nil {
+			fmt.Fprintf(sb, "%s%s:\n", indent, c.name)
+			if err := l.yamlSample(sb, c, indent+"  "); err != nil {
+				return err
+			}
+			continue
+		}
+
+		v, commented, err := l.sampleValue(*c.field)
+		if err != nil {
+			return err
+		}
+		b, err := marshalJSON(v)
+		if err != nil {
+			return err
+		}
+
+		if comment := sampleComment(*c.field); comment != "" {
+			fmt.Fprintf(sb, "%s# %s\n", indent, comment)
+		}
+		prefix := ""
+		if commented {
+			prefix = "# "
+		}
+		fmt.Fprintf(sb, "%s%s%s: %s\n", indent, prefix, c.name, b)
+	}
+
+	return nil
+}
+
+// tomlSample writes the fields of the node in toml, the nested structs are written as tables
+// after the fields.
+func (l *Loader) tomlSample(sb *strings.Builder, n *sampleNode, table []string) error {
+	for _, c := range n.children {
+		if c.field == nil {
+			continue
+		}
+
+		v, commented, err := l.sampleValue(*c.field)
+		if err != nil {
+			return err
+		}
+		value, err := tomlValue(v)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", c.field.Name, err)
+		}
+
+		if comment := sampleComment(*c.field); comment != "" {
+			fmt.Fprintf(sb, "# %s\n", comment)
+		}
+		prefix := ""
+		if commented {
+			prefix = "# "
+		}
+		fmt.Fprintf(sb, "%s%s = %s\n", prefix, c.name, value)
+	}
+
+	for _, c := range n.children {
+		if c.field != nil {
+			continue
+		}
+
+		name := append(append([]string(nil), table...), c.name)
+		fmt.Fprintf(sb, "\n[%s]\n", strings.Join(name, "."))
+		if err := l.tomlSample(sb, c, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tomlValue formats the JSON value in toml.
+func tomlValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return `""`, nil
+	case string:
+		b, err := marshalJSON(v)
+		return string(b), err
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("unsupported float value %v", v)
+		}
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s, nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, e := range v {
+			s, err := tomlValue(e)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		pairs := make([]string, 0, len(v))
+		for _, k := range keys {
+			s, err := tomlValue(v[k])
+			if err != nil {
+				return "", err
+			}
+			pairs = append(pairs, strconv.Quote(k)+" = "+s)
+		}
+		if len(pairs) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(pairs, ", ") + " }", nil
+	}
+
+	return fmt.Sprint(v), nil
+}
+
+// jsonSample returns the fields of the node as a JSON object.
+func (l *Loader) jsonSample(n *sampleNode) (map[string]interface{}, error) {
+	obj := make(map[string]interface{}, len(n.children))
+	for _, c := range n.children {
+		if c.field == nil {
+			child, err := l.jsonSample(c)
+			if err != nil {
+				return nil, err
+			}
+			obj[c.name] = child
+			continue
+		}
+
+		v, commented, err := l.sampleValue(*c.field)
+		if err != nil {
+			return nil, err
+		}
+		// json has no comments, null keeps the value of the field when the sample is loaded
+		if commented {
+			v = nil
+		}
+		obj[c.name] = v
+	}
+
+	return obj, nil
+}
+
+// envSample writes the environment variables of the fields with the values in the format of the
+// tags. The fields of the elements of the collections of structs are commented out, for example
+// UPSTREAMS_<N>_HOST, as the index or the key of the element is part of the name.
+func (l *Loader) envSample(sb *strings.Builder, fields []Field, commented bool) error {
+	for _, f := range fields {
+		if f.Args {
+			continue
+		}
+
+		if t := f.FieldValue.Type(); isStructCollection(l.types, t) {
+			elemFields, err := l.elementFields(t)
+			if err != nil {
+				return err
+			}
+			label := "<key>"
+			if t.Kind() == reflect.Slice {
+				label = "<n>"
+			}
+			if err := l.envSample(sb, prefixFields(f, elemFields, label), true); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// the masked fields and the values which can't be decoded are commented out like in yaml
+		value := f.Default
+		skip := commented || f.Mask
+		t := valueType(f.FieldValue.Type())
+		switch {
+		case f.Mask && value == "" && t.Kind() == reflect.String:
+			value, skip = secretPlaceholder, commented
+		case value == "" && !l.decodes(f, value):
+			skip = true
+		}
+
+		if comment := sampleComment(f); comment != "" {
+			fmt.Fprintf(sb, "# %s\n", comment)
+		}
+		prefix := ""
+		if skip {
+			prefix = "# "
+		}
+		fmt.Fprintf(sb, "%s%s=%s\n", prefix, f.EnvVar, envQuote(value))
+	}
+
+	return nil
+}
+
+// marshalJSON returns the JSON encoding of the value without escaping the HTML characters, so the
+// placeholders like <secret> stay readable.
+func marshalJSON(v interface{}) ([]byte, error) {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return []byte(strings.TrimSuffix(b.String(), "\n")), nil
+}
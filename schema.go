@@ -0,0 +1,354 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// schemaDraft is the JSON Schema dialect of the generated schema.
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// schema is a JSON Schema object.
+type schema map[string]interface{}
+
+// JSONSchema generates the JSON Schema (draft 2020-12) of the configuration files, so editors can validate
+// and complete the YAML and JSON files. The properties are named after the yaml or json tags and nested
+// like the structs, the schema of every field holds its type, default, allowed values, bounds and usage.
+// A required field is required in the object holding it, the values of the types decoded from text, like
+// time.Duration or ByteSize, are strings.
+func JSONSchema(cfg interface{}) (string, error) {
+	fields, err := NewLoader().extractFields(nil, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	root, err := objectSchema(fields)
+	if err != nil {
+		return "", err
+	}
+	root["$schema"] = schemaDraft
+	root["title"] = programName()
+
+	b, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(b) + "\n", nil
+}
+
+// objectSchema returns the schema of the object holding the fields, the fields of nested structs
+// are placed in nested objects.
+func objectSchema(fields []Field) (schema, error) {
+	root := schema{"type": "object"}
+	for _, f := range fields {
+		if f.Args || len(f.path) == 0 {
+			continue
+		}
+
+		s, err := fieldSchema(f)
+		if err != nil {
+			return nil, err
+		}
+
+		parent := root
+		for _, key := range f.path[:len(f.path)-1] {
+			parent = childSchema(parent, key)
+		}
+
+		name := f.path[len(f.path)-1]
+		properties(parent)[name] = s
+		if f.Required {
+			required, _ := parent["required"].([]string)
+			parent["required"] = append(required, name)
+		}
+	}
+
+	return root, nil
+}
+
+// properties returns the properties of the object schema.
+func properties(s schema) schema {
+	props, ok := s["properties"].(schema)
+	if !ok {
+		props = schema{}
+		s["properties"] = props
+	}
+
+	return props
+}
+
+// childSchema returns the schema of the nested object, it is added when it is missing.
+func childSchema(s schema, key string) schema {
+	props := properties(s)
+	child, ok := props[key].(schema)
+	if !ok {
+		child = schema{"type": "object"}
+		props[key] = child
+	}
+
+	return child
+}
+
+// fieldSchema returns the schema of the field with its type, usage, default and validation rules.
+func fieldSchema(f Field) (schema, error) {
+	t := valueType(f.FieldValue.Type())
+	opts := f.options()
+
+	s, err := typeSchema(t)
+	if err != nil {
+		return nil, fmt.Errorf("schema of field %s: %w", f.Name, err)
+	}
+
+	if f.Usage != "" {
+		s["description"] = f.Usage
+	}
+	if deprecation(f) != "" {
+		s["deprecated"] = true
+	}
+
+	if f.Default != "" {
+		def, err := schemaValue(f.Default, t, opts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default value for field %s: %w", f.Name, err)
+		}
+		s["default"] = def
+	}
+
+	if len(f.OneOf) > 0 {
+		// the allowed values of slices and arrays apply to their elements
+		target, et := s, t
+		if items, ok := s["items"].(schema); ok {
+			target, et = items, t.Elem()
+			opts = opts.elements()
+		}
+
+		enum := make([]interface{}, 0, len(f.OneOf))
+		for _, o := range f.OneOf {
+			v, err := schemaValue(o, et, opts)
+			if err != nil {
+				return nil, fmt.Errorf("invalid oneof value for field %s: %w", f.Name, err)
+			}
+			enum = append(enum, v)
+		}
+		target["enum"] = enum
+	}
+
+	if err := boundSchema(s, f, t, "min", f.Min); err != nil {
+		return nil, err
+	}
+	if err := boundSchema(s, f, t, "max", f.Max); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// boundSchema adds the min or max rule of the field to the schema: the length of strings, the number
+// of elements of slices and maps, and the value of numbers. The bounds of the types decoded from text
+// can't be expressed in the schema and are left out.
+func boundSchema(s schema, f Field, t reflect.Type, rule, bound string) error {
+	if bound == "" {
+		return nil
+	}
+
+	keywords := map[reflect.Kind][2]string{
+		reflect.String: {"minLength", "maxLength"},
+		reflect.Slice:  {"minItems", "maxItems"},
+		reflect.Array:  {"minItems", "maxItems"},
+		reflect.Map:    {"minProperties", "maxProperties"},
+	}
+
+	i := 0
+	if rule == "max" {
+		i = 1
+	}
+
+	if k, ok := keywords[t.Kind()]; ok && !isTextType(t) {
+		n, err := strconv.Atoi(bound)
+		if err != nil {
+			return fmt.Errorf("invalid %s rule for field %s: length bound must be an integer: %s", rule, f.Name, bound)
+		}
+		s[k[i]] = n
+		return nil
+	}
+
+	if s["type"] != "integer" && s["type"] != "number" {
+		return nil
+	}
+
+	v, err := schemaValue(bound, t, f.options())
+	if err != nil {
+		return fmt.Errorf("invalid %s rule for field %s: %w", rule, f.Name, err)
+	}
+	s[[2]string{"minimum", "maximum"}[i]] = v
+
+	return nil
+}
+
+// typeSchema returns the schema of the type.
+func typeSchema(t reflect.Type) (schema, error) {
+	t = valueType(t)
+
+	if isTextType(t) {
+		return schema{"type": "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return schema{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return schema{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schema{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}, nil
+	case reflect.String, reflect.Complex64, reflect.Complex128:
+		return schema{"type": "string"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		s := schema{"type": "array", "items": items}
+		if t.Kind() == reflect.Array {
+			s["minItems"] = t.Len()
+			s["maxItems"] = t.Len()
+		}
+		return s, nil
+	case reflect.Map:
+		values, err := typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return schema{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		fields, err := NewLoader().extractFields(nil, reflect.New(t).Interface())
+		if err != nil {
+			return nil, err
+		}
+		return objectSchema(fields)
+	case reflect.Interface:
+		return schema{}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// valueType returns the type of the values of the field type, the pointers are dereferenced except
+// for the built-in pointer types like *time.Location.
+func valueType(t reflect.Type) reflect.Type {
+	if _, ok := builtinTypes[t]; ok || t.Kind() != reflect.Ptr {
+		return t
+	}
+
+	return t.Elem()
+}
+
+// isTextType reports whether the values of the type are written as strings in the configuration
+// files: durations, byte slices and the types decoded from text.
+func isTextType(t reflect.Type) bool {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return true
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		return true
+	}
+	if t.Kind() == reflect.Array && t.Elem().Kind() == reflect.Uint8 {
+		return true
+	}
+
+	return isLeaf(nil, t)
+}
+
+// schemaValue decodes the value given with a tag into the type and returns it as a JSON value.
+func schemaValue(value string, t reflect.Type, opts fieldOptions) (interface{}, error) {
+	v := reflect.New(t).Elem()
+	if err := processField(value, v, opts); err != nil {
+		return nil, err
+	}
+
+	return jsonValue(v, opts), nil
+}
+
+// jsonValue returns the value as a JSON value: numbers and booleans keep their types, slices become
+// arrays and maps objects, the other values are formatted as strings.
+func jsonValue(v reflect.Value, opts fieldOptions) interface{} {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		if valueType(v.Type()) != v.Type() {
+			v = v.Elem()
+		}
+	}
+
+	if isTextType(v.Type()) {
+		return valueToString(v, opts)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Slice, reflect.Array:
+		list := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			list = append(list, jsonValue(v.Index(i), opts.elements()))
+		}
+		return list
+	case reflect.Map:
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[valueToString(iter.Key(), opts)] = jsonValue(iter.Value(), opts.elements())
+		}
+		return m
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return jsonValue(v.Elem(), opts)
+	case reflect.Struct:
+		return structValue(v)
+	}
+
+	return valueToString(v, opts)
+}
+
+// structValue returns the struct decoded as a whole, for example with the json format, as a JSON
+// object with the keys named after the yaml or json tags like the fields of the configuration files.
+func structValue(v reflect.Value) *object {
+	obj := newObject()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		key, inline := fileKey(sf)
+		if key == "" {
+			continue
+		}
+
+		value := jsonValue(v.Field(i), fieldOptions{})
+		if inner, ok := value.(*object); ok && inline {
+			for _, k := range inner.keys {
+				obj.set(k, inner.values[k])
+			}
+			continue
+		}
+		obj.set(key, value)
+	}
+
+	return obj
+}
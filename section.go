@@ -0,0 +1,77 @@
+package config
+
+import "reflect"
+
+// section is an optional section of the configuration, a nil pointer to a struct. Its fields are
+// processed in a new struct which is stored into the pointer only when any of the fields is set
+// from a source, so a nil pointer means the section is not configured.
+type section struct {
+	// field is the nil pointer field.
+	field reflect.Value
+
+	// value is the pointer to the new struct holding the fields of the section.
+	value reflect.Value
+
+	// parent is the section the field belongs to, nil for the top level fields.
+	parent *section
+
+	// set reports whether any field of the section is set.
+	set bool
+}
+
+// isSection reports whether the type is a pointer to a struct which is not decoded as a whole.
+func isSection(local typeRegistry, t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct && !isLeaf(local, t) && !isLeaf(local, t.Elem())
+}
+
+// markSet marks the section and its parents as set.
+func (s *section) markSet() {
+	for ; s != nil && !s.set; s = s.parent {
+		s.set = true
+	}
+}
+
+// active reports whether the fields of the section are in use, the fields which don't belong
+// to any optional section are always in use.
+func (s *section) active() bool {
+	return s == nil || s.set
+}
+
+// commit stores the new struct into the pointer field when the section is set.
+func (s *section) commit() {
+	if s.set {
+		s.field.Set(s.value)
+	}
+}
+
+// inSection places the fields, and the optional sections among them, in the section.
+func inSection(fields []Field, s *section) {
+	for i := range fields {
+		if fields[i].section == nil {
+			fields[i].section = s
+			continue
+		}
+
+		root := fields[i].section
+		for root.parent != nil {
+			root = root.parent
+		}
+		if root != s {
+			root.parent = s
+		}
+	}
+}
+
+// sections returns the optional sections of the fields.
+func sections(fields []Field) []*section {
+	var list []*section
+	seen := make(map[*section]bool)
+	for _, f := range fields {
+		for s := f.section; s != nil && !seen[s]; s = s.parent {
+			seen[s] = true
+			list = append(list, s)
+		}
+	}
+
+	return list
+}
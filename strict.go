@@ -0,0 +1,160 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrUnknownFlag is returned in strict mode when a flag doesn't match any field.
+	ErrUnknownFlag = errors.New("unknown flag")
+	// ErrUnknownEnv is returned in strict mode when an environment variable with the application
+	// prefix doesn't match any field.
+	ErrUnknownEnv = errors.New("unknown environment variable")
+)
+
+// builtinFlags are the flags intercepted by the package itself.
+var builtinFlags = []string{"help", "h", "version", "v"}
+
+// checkUnknown returns an error for every flag, and every environment variable starting with
+// the prefix, that doesn't map to any field.
+func checkUnknown(fields []Field, f *flag, e *env, prefix string) error {
+	flags := make(map[string]bool)
+	envs := make(map[string]bool)
+	for _, name := range builtinFlags {
+		flags[name] = true
+	}
+	for _, field := range fields {
+		if field.Args {
+			continue
+		}
+		flags[field.Flag] = true
+		if field.ShortFlag != 0 {
+			flags[string(field.ShortFlag)] = true
+		}
+		envs[field.EnvVar] = true
+	}
+
+	var errs []error
+	seen := make(map[string]bool)
+	for _, v := range f.values {
+		if flags[v.Name] || seen[v.Name] {
+			continue
+		}
+		seen[v.Name] = true
+
+		err := fmt.Errorf("%w: %s", ErrUnknownFlag, flagString(v.Name))
+		if s := suggest(v.Name, keys(flags)); len(s) > 0 {
+			for i := range s {
+				s[i] = flagString(s[i])
+			}
+			err = fmt.Errorf("%w (did you mean %s?)", err, strings.Join(s, " or "))
+		}
+		errs = append(errs, err)
+	}
+
+	if prefix != "" {
+		if !strings.HasSuffix(prefix, "_") {
+			prefix += "_"
+		}
+
+		var unknown []string
+		for name := range e.m {
+			if strings.HasPrefix(name, prefix) && !envs[name] {
+				unknown = append(unknown, name)
+			}
+		}
+		sort.Strings(unknown)
+
+		for _, name := range unknown {
+			err := fmt.Errorf("%w: %s", ErrUnknownEnv, name)
+			if s := suggest(name, keys(envs)); len(s) > 0 {
+				err = fmt.Errorf("%w (did you mean %s?)", err, strings.Join(s, " or "))
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// flagString returns the flag name as it is written on the command line.
+func flagString(name string) string {
+	if len([]rune(name)) == 1 {
+		return "-" + name
+	}
+
+	return "--" + name
+}
+
+// keys returns the sorted keys of the map.
+func keys(m map[string]bool) []string {
+	s := make([]string, 0, len(m))
+	for k := range m {
+		s = append(s, k)
+	}
+	sort.Strings(s)
+
+	return s
+}
+
+// suggest returns up to three candidates closest to the name by edit distance.
+// Only the candidates close enough to be a typo are returned.
+func suggest(name string, candidates []string) []string {
+	type match struct {
+		name string
+		dist int
+	}
+
+	var matches []match
+	for _, c := range candidates {
+		d := editDistance(strings.ToLower(name), strings.ToLower(c))
+		if d > 0 && d <= 2 && d*2 <= len([]rune(name)) {
+			matches = append(matches, match{name: c, dist: d})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].dist < matches[j].dist
+	})
+
+	var s []string
+	for i := 0; i < len(matches) && i < 3; i++ {
+		s = append(s, matches[i].name)
+	}
+
+	return s
+}
+
+// editDistance returns the optimal string alignment distance between a and b, which is
+// the Levenshtein distance that also counts a transposition of two adjacent characters
+// as a single edit, so "prot" is one edit away from "port".
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(ra)][len(rb)]
+}
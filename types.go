@@ -0,0 +1,114 @@
+package config
+
+import (
+	"reflect"
+	"sync"
+)
+
+// parseFunc decodes the string into a value of the registered type.
+type parseFunc func(value string) (reflect.Value, error)
+
+// formatFunc formats the value of the registered type.
+type formatFunc func(v reflect.Value) string
+
+// typeRegistry holds the decoders registered for the types which can't implement the Decoder
+// interface, for example the types of other packages.
+type typeRegistry map[reflect.Type]parseFunc
+
+var (
+	registryMu sync.RWMutex
+	// types are the decoders registered with RegisterType.
+	types = typeRegistry{}
+	// formatters are the formatters registered with RegisterFormatter.
+	formatters = map[reflect.Type]formatFunc{}
+)
+
+// RegisterType registers the function decoding the string into a value of type T. It is used by
+// every Loader for the fields of type T, including the elements of slices and maps and the targets
+// of pointers, and takes precedence over the Decoder interface and the built-in decoding.
+// Use WithType to register the type for a single Loader.
+//
+//	config.RegisterType(func(s string) (*big.Float, error) {
+//	    f, _, err := big.ParseFloat(s, 10, 256, big.ToNearestEven)
+//	    return f, err
+//	})
+func RegisterType[T any](parse func(string) (T, error)) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	types[typeOf[T]()] = newParseFunc(parse)
+}
+
+// RegisterFormatter registers the function formatting the values of type T in the startup message.
+func RegisterFormatter[T any](format func(T) string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	formatters[typeOf[T]()] = func(v reflect.Value) string {
+		return format(v.Interface().(T))
+	}
+}
+
+// WithType registers the function decoding the string into a value of type T for the Loader.
+// It takes precedence over the types registered with RegisterType. A struct type T is decoded
+// as a whole, its fields are not addressed one by one.
+func WithType[T any](parse func(string) (T, error)) Option {
+	return func(l *Loader) {
+		if l.types == nil {
+			l.types = typeRegistry{}
+		}
+		l.types[typeOf[T]()] = newParseFunc(parse)
+	}
+}
+
+// typeOf returns the reflect.Type of T, it works for interface types too.
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// newParseFunc wraps the typed parse function.
+func newParseFunc[T any](parse func(string) (T, error)) parseFunc {
+	return func(value string) (reflect.Value, error) {
+		v, err := parse(value)
+		return reflect.ValueOf(&v).Elem(), err
+	}
+}
+
+// lookupType returns the decoder of the type registered for the Loader or with RegisterType.
+func lookupType(local typeRegistry, t reflect.Type) (parseFunc, bool) {
+	if fn, ok := local[t]; ok {
+		return fn, true
+	}
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	fn, ok := types[t]
+	return fn, ok
+}
+
+// lookupFormatter returns the formatter registered for the type with RegisterFormatter.
+func lookupFormatter(t reflect.Type) (formatFunc, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	fn, ok := formatters[t]
+	return fn, ok
+}
+
+// processType decodes the value with the decoder registered for the type of the field. It reports
+// whether a decoder is registered for the type.
+func processType(value string, field reflect.Value, opts fieldOptions) (bool, error) {
+	fn, ok := lookupType(opts.types, field.Type())
+	if !ok {
+		return false, nil
+	}
+
+	v, err := fn(value)
+	if err != nil {
+		return true, err
+	}
+
+	field.Set(v)
+	return true, nil
+}
@@ -0,0 +1,237 @@
+package config
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ByteSize is a size in bytes that can be decoded from a human-readable string like 512KiB, 10MB
+// or 1.5GiB. Decimal units (KB, MB, GB, TB, PB) are powers of 1000 and binary units (KiB, MiB, GiB,
+// TiB, PiB) are powers of 1024. A number without unit is a number of bytes.
+type ByteSize uint64
+
+// Byte sizes in decimal and binary units.
+const (
+	Byte ByteSize = 1
+
+	KB ByteSize = 1000 * Byte
+	MB ByteSize = 1000 * KB
+	GB ByteSize = 1000 * MB
+	TB ByteSize = 1000 * GB
+	PB ByteSize = 1000 * TB
+
+	KiB ByteSize = 1024 * Byte
+	MiB ByteSize = 1024 * KiB
+	GiB ByteSize = 1024 * MiB
+	TiB ByteSize = 1024 * GiB
+	PiB ByteSize = 1024 * TiB
+)
+
+// byteUnits are the units of ByteSize from the largest to the smallest.
+var byteUnits = []struct {
+	name string
+	size ByteSize
+}{
+	{"PiB", PiB}, {"PB", PB},
+	{"TiB", TiB}, {"TB", TB},
+	{"GiB", GiB}, {"GB", GB},
+	{"MiB", MiB}, {"MB", MB},
+	{"KiB", KiB}, {"KB", KB},
+	{"B", Byte},
+}
+
+// Decode implements the Decoder interface.
+func (b *ByteSize) Decode(val string) error {
+	num, unit := splitNumber(val)
+	if num == "" {
+		return errors.New("invalid byte size: " + val)
+	}
+
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return errors.New("invalid byte size: " + val)
+	}
+
+	size := Byte
+	if unit != "" {
+		found := false
+		for _, u := range byteUnits {
+			if strings.EqualFold(u.name, unit) {
+				size, found = u.size, true
+				break
+			}
+		}
+		if !found {
+			return errors.New("invalid byte size unit: " + val)
+		}
+	}
+
+	bytes := math.Round(n * float64(size))
+	if bytes >= math.MaxUint64 {
+		return errors.New("byte size out of range: " + val)
+	}
+
+	*b = ByteSize(bytes)
+	return nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	return b.Decode(string(text))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// String returns the size with the largest unit that represents it exactly with at most two
+// decimal places, for example 1.5GiB or 10MB. The result can be decoded back to the same size.
+func (b ByteSize) String() string {
+	for _, u := range byteUnits {
+		if b < u.size {
+			continue
+		}
+
+		s := strconv.FormatFloat(float64(b)/float64(u.size), 'f', -1, 64)
+		if i := strings.IndexByte(s, '.'); i >= 0 && len(s)-i-1 > 2 {
+			continue
+		}
+
+		var check ByteSize
+		if err := check.Decode(s + u.name); err == nil && check == b {
+			return s + u.name
+		}
+	}
+
+	return strconv.FormatUint(uint64(b), 10) + "B"
+}
+
+// Bytes returns the size in bytes.
+func (b ByteSize) Bytes() uint64 {
+	return uint64(b)
+}
+
+// Rate is a number of events per period that can be decoded from a string like 100/s, 5000/min or
+// 10/5m. The period is one of s, sec, second, m, min, minute, h, hour, d, day or a duration
+// like 5m or 100ms.
+type Rate struct {
+	// Count is the number of events per period.
+	Count float64
+
+	// Per is the period.
+	Per time.Duration
+}
+
+// ratePeriods are the names of the periods of Rate.
+var ratePeriods = map[string]time.Duration{
+	"ms":     time.Millisecond,
+	"s":      time.Second,
+	"sec":    time.Second,
+	"second": time.Second,
+	"m":      time.Minute,
+	"min":    time.Minute,
+	"minute": time.Minute,
+	"h":      time.Hour,
+	"hour":   time.Hour,
+	"d":      24 * time.Hour,
+	"day":    24 * time.Hour,
+}
+
+// Decode implements the Decoder interface.
+func (r *Rate) Decode(val string) error {
+	count, period, ok := strings.Cut(strings.TrimSpace(val), "/")
+	if !ok {
+		return errors.New("invalid rate, expected count/period like 100/s: " + val)
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(count), 64)
+	if err != nil || n < 0 {
+		return errors.New("invalid rate count: " + val)
+	}
+
+	period = strings.TrimSpace(period)
+	per, ok := ratePeriods[strings.ToLower(period)]
+	if !ok {
+		per, err = time.ParseDuration(period)
+		if err != nil || per <= 0 {
+			return errors.New("invalid rate period: " + val)
+		}
+	}
+
+	*r = Rate{Count: n, Per: per}
+	return nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (r *Rate) UnmarshalText(text []byte) error {
+	return r.Decode(string(text))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (r Rate) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// String returns the rate in the form it is decoded from, for example 100/s or 5000/min.
+func (r Rate) String() string {
+	if r.Per == 0 {
+		return ""
+	}
+
+	count := strconv.FormatFloat(r.Count, 'f', -1, 64)
+	switch r.Per {
+	case time.Millisecond:
+		return count + "/ms"
+	case time.Second:
+		return count + "/s"
+	case time.Minute:
+		return count + "/min"
+	case time.Hour:
+		return count + "/h"
+	case 24 * time.Hour:
+		return count + "/day"
+	}
+
+	return count + "/" + r.Per.String()
+}
+
+// PerSecond returns the number of events per second.
+func (r Rate) PerSecond() float64 {
+	if r.Per == 0 {
+		return 0
+	}
+
+	return r.Count / r.Per.Seconds()
+}
+
+// Every returns the interval between two events, zero when the count is zero.
+func (r Rate) Every() time.Duration {
+	if r.Count == 0 {
+		return 0
+	}
+
+	return time.Duration(float64(r.Per) / r.Count)
+}
+
+// order returns the value used to compare rates in the min and max rules.
+func (r Rate) order() float64 {
+	return r.PerSecond()
+}
+
+// splitNumber splits the string into the leading number and the trailing unit.
+func splitNumber(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	})
+	if i < 0 {
+		return s, ""
+	}
+
+	return s[:i], strings.TrimSpace(s[i:])
+}
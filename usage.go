@@ -5,57 +5,158 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"text/template"
+	"unicode/utf8"
 )
 
 // usageTemplate is the template for the usage message.
-var usageTemplate = `Usage: {{.AppName}} [options] [arguments]
-
-{{if .Description}}{{.Description}}{{end}}
-
-Options:
-{{range .Field }}
+var usageTemplate = `{{define "fields"}}{{range . }}
 {{- if .ShortFlag }}
 	{{- printf "\t-%c," .ShortFlag }}
 {{- else}}
-	{{- printf "\t " }}
+	{{- printf "\t   " }}
 {{- end }}
 {{- if .Flag }}
-	{{- printf "\t--%s | $%s %s" .Flag .EnvVar (formatFieldType .FieldValue) }}
+	{{- printf "\t%s | $%s %s" (formatFlag .) .EnvVar (formatFieldType .FieldValue) }}
 {{- end }}
 	{{- printf "\t%s" (formatField .Default .Usage .Required) }}
-{{ end }}
+{{ end }}{{end -}}
+{{if .Header}}{{.Header}}
+
+{{end -}}
+Usage: {{.AppName}}{{if .Command}} {{.Command}}{{end}} [options]{{if .Commands}} <command>{{end}} [arguments]
+
+{{if .Description}}{{.Description}}{{end}}
+{{- if .Commands}}
+
+Commands:
+{{- range .Commands}}
+	{{- printf "\n\t%s\t%s" .Name .Usage }}
+{{- end}}
+{{- end}}
+
+Options:
+{{range .Groups}}{{if .Name}}
+{{.Name}} Options:
+{{end}}{{template "fields" .Fields}}{{end}}
 Global Options:
-	{{ printf "\t -h," }}{{ printf "\t--help" }}{{ printf "\tshow this help message" }}
-	{{ printf "\t -v," }}{{ printf "\t--version" }}{{ printf "\tshow version" }}
+{{template "fields" .Global}}
+	{{- printf "\t-h," }}{{ printf "\t--help" }}{{ printf "\tshow this help message" }}
+{{ printf "\t-v," }}{{ printf "\t--version" }}{{ printf "\tshow version" }}
+{{- if .Examples}}
+
+Examples:
+{{- range .Examples}}
+	{{- printf "\n  %s" . }}
+{{- end}}
+{{- end}}
+{{- if .Footer}}
+
+{{.Footer}}
+{{- end}}
 `
 
-// UsageMessage generates the usage message.
-func UsageMessage(cfg interface{}) (string, error) {
-	usage, err := extractFields(nil, cfg)
+// defaultDescription is the description of the usage message.
+const defaultDescription = "Configure the application using environment variables and command line flags. See options below."
+
+// usageData is the data of the usage template.
+type usageData struct {
+	AppName     string
+	Command     string
+	Description string
+	Header      string
+	Footer      string
+	Examples    []string
+	Field       []Field
+	Groups      []fieldGroup
+	Global      []Field
+	Commands    []Command
+}
+
+// UsageMessage generates the usage message. The options of the Loader set the program name, the
+// description, the header and the footer, the examples, the template and the wrap width of the message.
+func UsageMessage(cfg interface{}, opts ...Option) (string, error) {
+	l := NewLoader(opts...)
+	fields, err := l.extractFields(nil, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	return l.usage(usageData{
+		Description: defaultDescription,
+		Field:       l.optionFields(fields),
+	})
+}
+
+// PrintUsage writes the usage message to the output of the Loader, see WithOutput.
+func PrintUsage(cfg interface{}, opts ...Option) error {
+	l := NewLoader(opts...)
+	usage, err := UsageMessage(cfg, opts...)
+	if err != nil {
+		return err
+	}
+
+	return l.write(usage)
+}
+
+// usage generates the usage message with the settings of the Loader. The fields are grouped by
+// the nested struct they belong to.
+func (l *Loader) usage(data usageData) (string, error) {
+	data.AppName = appName()
+	if l.programName != "" {
+		data.AppName = l.programName
+	}
+	if l.description != "" && data.Command == "" {
+		data.Description = l.description
+	}
+	data.Header = l.usageHeader
+	data.Footer = l.usageFooter
+	data.Examples = l.examples
+	data.Groups = groupFields(data.Field)
+
+	tmpl := usageTemplate
+	if l.usageTemplate != "" {
+		tmpl = l.usageTemplate
+	}
+
+	msg, err := usageMessage(tmpl, data)
 	if err != nil {
 		return "", err
 	}
 
+	return wrapLines(msg, l.width()), nil
+}
+
+// width returns the width the usage message is wrapped at, zero when it is not wrapped.
+func (l *Loader) width() int {
+	if l.wrapWidth != 0 {
+		return max(l.wrapWidth, 0)
+	}
+
+	n, _ := strconv.Atoi(os.Getenv("COLUMNS"))
+	return n
+}
+
+// usageMessage executes the usage template with the given data.
+func usageMessage(tmpl string, data usageData) (string, error) {
 	funcMap := template.FuncMap{
 		"formatFieldType": formatFieldType,
+		"formatFlag":      formatFlag,
 		"formatField":     formatField,
 	}
 
+	t, err := template.New("usage").Funcs(funcMap).Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
 	var sb strings.Builder
 	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', tabwriter.TabIndent)
 
-	err = template.Must(template.New("usage").Funcs(funcMap).Parse(usageTemplate)).Execute(w, struct {
-		AppName     string
-		Description string
-		Field       []Field
-	}{
-		AppName:     os.Args[0],
-		Description: "Configure the application using environment variables and command line flags. See options below.",
-		Field:       usage,
-	})
+	err = t.Execute(w, data)
 	if err != nil {
 		return "", err
 	}
@@ -68,18 +169,110 @@ func UsageMessage(cfg interface{}) (string, error) {
 	return sb.String(), nil
 }
 
-// StartupMessage generates the startup message.
-func StartupMessage(cfg interface{}) (string, error) {
-	cfgUsage, err := extractFields(nil, cfg)
+// wrapLines wraps the lines longer than the width at the spaces between the words. The continuation
+// lines of the options are indented to their description column, the other lines to their indentation.
+func wrapLines(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		lines = append(lines, wrapLine(line, width)...)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// wrapLine wraps the line at the width.
+func wrapLine(line string, width int) []string {
+	if utf8.RuneCountInString(line) <= width {
+		return []string{line}
+	}
+
+	// the text starts after the indentation or, for the options, after the last gap between the columns
+	indent := len(line) - len(strings.TrimLeft(line, " "))
+	start := indent
+	if i := strings.LastIndex(line[indent:], "  "); i >= 0 {
+		start = indent + i
+		for start < len(line) && line[start] == ' ' {
+			start++
+		}
+	}
+	if start >= width/2 {
+		start = indent
+	}
+
+	words := strings.Fields(line[start:])
+	if len(words) == 0 {
+		return []string{line}
+	}
+
+	prefix := strings.Repeat(" ", start)
+	current := line[:start] + words[0]
+	var lines []string
+	for _, w := range words[1:] {
+		if utf8.RuneCountInString(current)+1+utf8.RuneCountInString(w) > width {
+			lines = append(lines, current)
+			current = prefix + w
+			continue
+		}
+		current += " " + w
+	}
+
+	return append(lines, current)
+}
+
+// optionFields returns the fields that are shown as options, positional arguments and hidden fields are not options.
+func (l *Loader) optionFields(fields []Field) []Field {
+	usage := make([]Field, 0, len(fields))
+	for _, f := range fields {
+		if f.Args || f.Hidden {
+			continue
+		}
+
+		// the collections of structs are listed with the fields of their elements,
+		// for example --upstreams.<n>.host
+		if t := f.FieldValue.Type(); isStructCollection(l.types, t) {
+			if elemFields, err := l.elementFields(t); err == nil {
+				label := "<key>"
+				if t.Kind() == reflect.Slice {
+					label = "<n>"
+				}
+				usage = append(usage, l.optionFields(prefixFields(f, elemFields, label))...)
+				continue
+			}
+		}
+
+		usage = append(usage, f)
+	}
+
+	return usage
+}
+
+// appName returns the name of the application from the command line arguments.
+func appName() string {
+	if len(os.Args) > 0 {
+		return os.Args[0]
+	}
+
+	return ""
+}
+
+// StartupMessage generates the startup message. The options of the Loader, for example the
+// delimiter set with WithDelimiter, are used to format the values, so they can be decoded back.
+func StartupMessage(cfg interface{}, opts ...Option) (string, error) {
+	l := NewLoader(opts...)
+	cfgUsage, _, err := l.currentFields(cfg)
 	if err != nil {
 		return "", err
 	}
 
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("%s is starting up with the following configuration:\n", os.Args[0]))
+	sb.WriteString(fmt.Sprintf("%s is starting up with the following configuration:\n", appName()))
 	for _, f := range cfgUsage {
-		val := valueToString(f.FieldValue)
-		sb.WriteString(fmt.Sprintf("--%s: %v\n", f.Flag, maskString(val, f.Mask)))
+		val := valueToString(f.FieldValue, l.options(f))
+		sb.WriteString(fmt.Sprintf("--%s: %v\n", f.Flag, maskString(val, f.MaskMode)))
 
 	}
 
@@ -87,15 +280,16 @@ func StartupMessage(cfg interface{}) (string, error) {
 }
 
 // JSONStartupMessage generates the startup message in JSON format.
-func JSONStartupMessage(cfg interface{}) (string, error) {
-	cfgUsage, err := extractFields(nil, cfg)
+func JSONStartupMessage(cfg interface{}, opts ...Option) (string, error) {
+	l := NewLoader(opts...)
+	cfgUsage, _, err := l.currentFields(cfg)
 	if err != nil {
 		return "", err
 	}
 
 	startupMessage := make(map[string]interface{})
 	for _, f := range cfgUsage {
-		startupMessage[f.Flag] = maskString(valueToString(f.FieldValue), f.Mask)
+		startupMessage[f.Flag] = maskString(valueToString(f.FieldValue, l.options(f)), f.MaskMode)
 	}
 
 	jsonMsg, err := json.Marshal(startupMessage)
@@ -106,12 +300,28 @@ func JSONStartupMessage(cfg interface{}) (string, error) {
 	return string(jsonMsg), nil
 }
 
-// maskString masks the string if the mask is set to true.
-func maskString(s string, mask bool) string {
-	if mask && len(s) > 3 {
-		return strings.Repeat("*", len(s)-3) + s[len(s)-3:]
+// currentFields returns the fields in use in the configuration: the collections of structs are
+// replaced with the fields of their elements and the optional sections which are not set are left
+// out. The returned functions store the map elements back into the maps.
+func (l *Loader) currentFields(cfg interface{}) ([]Field, []func(), error) {
+	fields, err := l.extractFields(nil, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fields, commits, err := l.expandFields(fields, nil, nil)
+	if err != nil {
+		return nil, nil, err
 	}
-	return s
+
+	current := make([]Field, 0, len(fields))
+	for _, f := range fields {
+		if !isStructCollection(l.types, f.FieldValue.Type()) && f.section.active() {
+			current = append(current, f)
+		}
+	}
+
+	return current, commits, nil
 }
 
 // formatField formats the field information into a single string.
@@ -132,12 +342,32 @@ func formatField(defaultValue, usage string, required bool) string {
 	return value
 }
 
+// formatFlag formats the long flag of the field, boolean flags are shown with their negation.
+func formatFlag(f Field) string {
+	if isBoolField(f.FieldValue) {
+		return "--[no-]" + f.Flag
+	}
+
+	return "--" + f.Flag
+}
+
 // formatFieldType formats the field type into a single human-readable string.
 func formatFieldType(f reflect.Value) string {
+	t := f.Type()
 	// check if field is time.Duration type and format accordingly
-	if f.Type().String() == "time.Duration" {
+	if t.String() == "time.Duration" {
 		return "duration"
 	}
 
-	return f.Type().String()
+	// built-in types have friendly names, for pointers too
+	if b, ok := builtinTypes[t]; ok {
+		return b.name
+	}
+	if t.Kind() == reflect.Ptr {
+		if b, ok := builtinTypes[t.Elem()]; ok {
+			return b.name
+		}
+	}
+
+	return t.String()
 }
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// orderedValue is implemented by the struct types that can be compared by the min and max rules.
+type orderedValue interface {
+	order() float64
+}
+
+// validateField validates the value of the field against the rules given with the tags. The zero
+// value is validated only when it is set by a source, unset fields are not validated, use the
+// required tag to enforce a value.
+func validateField(f Field, opts fieldOptions, set bool) error {
+	if !set && f.FieldValue.IsZero() {
+		return nil
+	}
+
+	// a nil pointer, for example set with null in yaml, has no value to validate
+	if f.FieldValue.Kind() == reflect.Ptr && f.FieldValue.IsNil() {
+		return nil
+	}
+
+	if f.Min != "" {
+		c, err := compareBound(f.FieldValue, f.Min, opts)
+		if err != nil {
+			return fmt.Errorf("invalid min rule for field %s: %w", f.Name, err)
+		}
+		if c < 0 {
+			return fmt.Errorf("invalid value for field %s: %s, must be at least %s", f.Name, valueToString(f.FieldValue, opts), f.Min)
+		}
+	}
+
+	if f.Max != "" {
+		c, err := compareBound(f.FieldValue, f.Max, opts)
+		if err != nil {
+			return fmt.Errorf("invalid max rule for field %s: %w", f.Name, err)
+		}
+		if c > 0 {
+			return fmt.Errorf("invalid value for field %s: %s, must be at most %s", f.Name, valueToString(f.FieldValue, opts), f.Max)
+		}
+	}
+
+	if len(f.OneOf) > 0 {
+		for _, v := range elementsToString(f.FieldValue, opts) {
+			if !contains(f.OneOf, v) {
+				return fmt.Errorf("invalid value for field %s: %q, must be one of: %s", f.Name, v, strings.Join(f.OneOf, ", "))
+			}
+		}
+	}
+
+	return nil
+}
+
+// compareBound compares the value with the bound decoded into the same type, so the bound can be
+// given in the units of the type, for example 1MiB for ByteSize or 1m for time.Duration. Strings are
+// compared by length, slices and maps by the number of elements. It returns -1, 0 or 1 when the value
+// is less than, equal to or greater than the bound.
+func compareBound(v reflect.Value, bound string, opts fieldOptions) (int, error) {
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		n, err := strconv.Atoi(bound)
+		if err != nil {
+			return 0, errors.New("length bound must be an integer: " + bound)
+		}
+		l := v.Len()
+		if v.Kind() == reflect.String {
+			l = utf8.RuneCountInString(v.String())
+		}
+		return cmp.Compare(l, n), nil
+	}
+
+	b := reflect.New(v.Type()).Elem()
+	if err := processField(bound, b, opts); err != nil {
+		return 0, err
+	}
+
+	if o, ok := v.Interface().(orderedValue); ok {
+		return cmp.Compare(o.order(), b.Interface().(orderedValue).order()), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(v.Int(), b.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmp.Compare(v.Uint(), b.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(v.Float(), b.Float()), nil
+	}
+
+	return 0, errors.New("unsupported type " + v.Type().String())
+}
+
+// elementsToString returns the string representation of every element of slices and arrays
+// and of the value itself for other kinds.
+func elementsToString(v reflect.Value, opts fieldOptions) []string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8 {
+		vals := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			vals = append(vals, valueToString(v.Index(i), opts.elements()))
+		}
+		return vals
+	}
+
+	return []string{valueToString(v, opts)}
+}
+
+// contains reports whether the slice contains the string.
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+
+	return false
+}
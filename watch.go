@@ -0,0 +1,344 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// defaultWatchInterval is the default interval of polling the watched files.
+const defaultWatchInterval = time.Second
+
+// Value holds the configuration which can be replaced at runtime, for example by a Watcher.
+// It is safe for concurrent use.
+type Value[T any] struct {
+	v atomic.Pointer[T]
+
+	// mu guards the subscribers and the pending changes, it is not held while the subscribers
+	// are called, so they can subscribe, unsubscribe and store
+	mu     sync.Mutex
+	subs   []subscriber[T]
+	nextID int
+
+	// pending are the changes not delivered yet, notifying reports whether a Store is delivering
+	// them, so the subscribers see the changes in order
+	pending   []change[T]
+	notifying bool
+}
+
+// change is a swap of the configuration delivered to the subscribers.
+type change[T any] struct {
+	old, new T
+}
+
+// subscriber is the callback registered with Subscribe.
+type subscriber[T any] struct {
+	id int
+	fn func(old, new T)
+}
+
+// NewValue returns the Value holding the configuration.
+func NewValue[T any](v T) *Value[T] {
+	val := &Value[T]{}
+	val.v.Store(&v)
+
+	return val
+}
+
+// Load returns the current configuration.
+func (v *Value[T]) Load() T {
+	if p := v.v.Load(); p != nil {
+		return *p
+	}
+
+	var zero T
+	return zero
+}
+
+// Store replaces the configuration and calls the subscribers with the old and the new configuration.
+// The changes are delivered in order: a Store called while the subscribers of another change are
+// running, for example from a subscriber, returns at once and its change is delivered next by the
+// Store already delivering the changes.
+func (v *Value[T]) Store(new T) {
+	if v.swap(new) {
+		v.deliver()
+	}
+}
+
+// swap replaces the configuration and queues the change for the subscribers. It reports whether
+// the caller has to deliver the changes, false when another Store is delivering them.
+func (v *Value[T]) swap(new T) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	old := v.Load()
+	v.v.Store(&new)
+	v.pending = append(v.pending, change[T]{old: old, new: new})
+	if v.notifying {
+		return false
+	}
+	v.notifying = true
+
+	return true
+}
+
+// deliver calls the subscribers with the pending changes until none is left.
+func (v *Value[T]) deliver() {
+	// a panicking subscriber stops the delivery, the panic is propagated and the changes left
+	// are delivered by the next Store, before its own change
+	delivered := false
+	defer func() {
+		if !delivered {
+			v.mu.Lock()
+			v.notifying = false
+			v.mu.Unlock()
+		}
+	}()
+
+	for {
+		v.mu.Lock()
+		if len(v.pending) == 0 {
+			v.notifying = false
+			v.mu.Unlock()
+			delivered = true
+			return
+		}
+		c := v.pending[0]
+		v.pending = v.pending[1:]
+		subs := append([]subscriber[T](nil), v.subs...)
+		v.mu.Unlock()
+
+		for _, s := range subs {
+			s.fn(c.old, c.new)
+		}
+	}
+}
+
+// Subscribe registers the function called after every change of the configuration and returns
+// the function removing the subscription. The functions are called in the order of subscription.
+func (v *Value[T]) Subscribe(fn func(old, new T)) (unsubscribe func()) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.nextID++
+	id := v.nextID
+	v.subs = append(v.subs, subscriber[T]{id: id, fn: fn})
+
+	return func() {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+
+		for i, s := range v.subs {
+			if s.id == id {
+				v.subs = append(v.subs[:i], v.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Watcher reloads the configuration when the watched files change or when SIGHUP arrives. Every reload
+// runs the full pipeline of the Loader: the parsers, the environment variables, the command line
+// flags and the validation. An invalid configuration is never applied, the error is reported to the
+// function set with WithReloadError and the Value keeps the last valid configuration.
+//
+//	w, err := config.NewWatcher[AppConfig](
+//	    config.WithParsers(yaml.File("config.yaml")),
+//	    config.WithReloadSignal(),
+//	)
+//	w.Value().Subscribe(func(old, new AppConfig) {
+//	    logger.SetLevel(new.LogLevel)
+//	})
+//	go w.Run(ctx)
+type Watcher[T any] struct {
+	loader *Loader
+	value  *Value[T]
+
+	// mu serializes the reloads, it is not held while the subscribers are called
+	mu sync.Mutex
+}
+
+// watchedFile is the state of a watched file used to find out whether it has changed.
+type watchedFile struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// NewWatcher loads the configuration with a Loader configured with the given options and returns the
+// Watcher holding it. Besides the files set with WithWatchFiles, the files of the parsers with a Path
+// method, like yaml.File, are watched.
+func NewWatcher[T any](opts ...Option) (*Watcher[T], error) {
+	l := NewLoader(opts...)
+
+	var cfg T
+	if err := l.Load(&cfg); err != nil {
+		return nil, err
+	}
+
+	return &Watcher[T]{
+		loader: l,
+		value:  NewValue(cfg),
+	}, nil
+}
+
+// Value returns the Value holding the current configuration.
+func (w *Watcher[T]) Value() *Value[T] {
+	return w.value
+}
+
+// Subscribe registers the function called after every change of the configuration with the changes
+// of the fields and returns the function removing the subscription.
+func (w *Watcher[T]) Subscribe(fn func(old, new T, changes Changes)) (unsubscribe func()) {
+	return w.value.Subscribe(func(old, new T) {
+		changes, _ := w.loader.diff(&old, &new)
+		fn(old, new, changes)
+	})
+}
+
+// Reload loads the configuration again and stores it into the Value when it is valid and differs
+// from the current configuration. The static fields, tagged with reload:"false", keep their values
+// and a *RestartError listing their changes is returned, the other fields are applied. With
+// WithRejectStaticChanges the reload changing static fields is not applied at all.
+func (w *Watcher[T]) Reload() error {
+	deliver, err := w.load()
+
+	// the subscribers are called without holding the lock of the reloads,
+	// so they can reload the configuration too
+	if deliver {
+		w.value.deliver()
+	}
+
+	return err
+}
+
+// load loads the configuration and swaps it into the Value, the reloads are serialized so the
+// changes are queued in the order of the loads. It reports whether the caller has to deliver
+// the changes to the subscribers.
+func (w *Watcher[T]) load() (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var cfg T
+	if err := w.loader.Load(&cfg); err != nil {
+		return false, fmt.Errorf("reload configuration: %w", err)
+	}
+
+	old := w.value.Load()
+	changes, err := w.loader.diff(&old, &cfg)
+	if err != nil {
+		return false, fmt.Errorf("reload configuration: %w", err)
+	}
+
+	var static []Change
+	for _, c := range changes {
+		if c.Static {
+			static = append(static, c)
+		}
+	}
+
+	if len(static) > 0 {
+		if w.loader.rejectStatic {
+			return false, &RestartError{Changes: static}
+		}
+		if err := w.loader.keepStatic(&old, &cfg); err != nil {
+			return false, fmt.Errorf("reload configuration: %w", err)
+		}
+	}
+
+	deliver := false
+	if !reflect.DeepEqual(old, cfg) {
+		deliver = w.value.swap(cfg)
+	}
+
+	if len(static) > 0 {
+		return deliver, &RestartError{Changes: static}
+	}
+
+	return deliver, nil
+}
+
+// Run polls the watched files and, with WithReloadSignal, listens for SIGHUP until the context
+// is done. The configuration is reloaded once the changed files stay the same for one interval.
+func (w *Watcher[T]) Run(ctx context.Context) error {
+	var sig chan os.Signal
+	if w.loader.reloadSignal {
+		sig = make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGHUP)
+		defer signal.Stop(sig)
+	}
+
+	interval := w.loader.watchInterval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	files := w.files()
+	state := statFiles(files)
+
+	// the reload waits until the changed files stay the same for one interval,
+	// so a file which is still being written is not loaded
+	pending := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-sig:
+			w.reload()
+		case <-ticker.C:
+			next := statFiles(files)
+			if !reflect.DeepEqual(state, next) {
+				state = next
+				pending = true
+				continue
+			}
+			if pending {
+				pending = false
+				w.reload()
+			}
+		}
+	}
+}
+
+// reload reloads the configuration and reports the error.
+func (w *Watcher[T]) reload() {
+	if err := w.Reload(); err != nil && w.loader.reloadError != nil {
+		w.loader.reloadError(err)
+	}
+}
+
+// files returns the watched files.
+func (w *Watcher[T]) files() []string {
+	files := append([]string(nil), w.loader.watchFiles...)
+	for _, p := range w.loader.parsers {
+		if f, ok := p.(interface{ Path() string }); ok && f.Path() != "" {
+			files = append(files, f.Path())
+		}
+	}
+
+	return files
+}
+
+// statFiles returns the state of the files.
+func statFiles(files []string) map[string]watchedFile {
+	state := make(map[string]watchedFile, len(files))
+	for _, name := range files {
+		fi, err := os.Stat(name)
+		if err != nil {
+			state[name] = watchedFile{}
+			continue
+		}
+		state[name] = watchedFile{exists: true, size: fi.Size(), modTime: fi.ModTime()}
+	}
+
+	return state
+}
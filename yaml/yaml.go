@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"os"
 
 	"gopkg.in/yaml.v3"
 )
@@ -14,6 +15,7 @@ import (
 // executed to apply value to config struct fields.
 type YAML struct {
 	data []byte
+	path string
 }
 
 // WithData accepts the yaml document as a slice of bytes.
@@ -35,11 +37,150 @@ func Reader(r io.Reader) YAML {
 	}
 }
 
+// File accepts the path of the yaml file. The file is read on every Parse, so the changes of the
+// file are picked up when the configuration is reloaded.
+func File(path string) YAML {
+	return YAML{
+		path: path,
+	}
+}
+
+// Path returns the path of the yaml file, empty when the yaml is not read from a file.
+// The config.Watcher watches the file for changes.
+func (y YAML) Path() string {
+	return y.path
+}
+
 // Parse performs the actual processing of the yaml. It unmarshal the yaml into the config struct.
 func (y YAML) Parse(cfg interface{}) error {
-	err := yaml.Unmarshal(y.data, cfg)
+	data, err := y.read()
+	if err != nil {
+		return err
+	}
+
+	err = yaml.Unmarshal(data, cfg)
 	if err != nil {
 		return fmt.Errorf("unmarshal yaml: %w", err)
 	}
 	return nil
 }
+
+// Keys returns the paths of the keys present in the yaml, for example [http port] for the port key
+// nested under http. The config.Loader validates the fields set by these keys even when they hold
+// the zero value.
+func (y YAML) Keys() ([][]string, error) {
+	data, err := y.read()
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("unmarshal yaml: %w", err)
+	}
+
+	var keys [][]string
+	for _, n := range doc.Content {
+		keys = appendKeys(keys, nil, n)
+	}
+
+	return keys, nil
+}
+
+// ParseText unmarshals the yaml into the config struct like Parse, except the scalar values of the keys
+// at the given paths, which are left out and returned as text keyed by the index of the path. The
+// config.Loader decodes them itself, so the types yaml has no representation for, like url.URL or
+// os.FileMode, are read from the same text as the environment variables.
+func (y YAML) ParseText(cfg interface{}, paths [][]string) (map[int]string, error) {
+	data, err := y.read()
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("unmarshal yaml: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	texts := make(map[int]string)
+	for i, path := range paths {
+		if v, ok := takeScalar(doc.Content[0], path); ok {
+			texts[i] = v
+		}
+	}
+
+	if err := doc.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("unmarshal yaml: %w", err)
+	}
+
+	return texts, nil
+}
+
+// read returns the yaml document, the file is read on every call.
+func (y YAML) read() ([]byte, error) {
+	if y.path == "" {
+		return y.data, nil
+	}
+
+	data, err := os.ReadFile(y.path)
+	if err != nil {
+		return nil, fmt.Errorf("read yaml: %w", err)
+	}
+
+	return data, nil
+}
+
+// appendKeys appends the paths of the keys of the mapping node and of the mappings nested in it.
+func appendKeys(keys [][]string, prefix []string, n *yaml.Node) [][]string {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n == nil || n.Kind != yaml.MappingNode {
+		return keys
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		path := append(append([]string(nil), prefix...), n.Content[i].Value)
+		keys = append(keys, path)
+		keys = appendKeys(keys, path, n.Content[i+1])
+	}
+
+	return keys
+}
+
+// takeScalar removes the key at the path from the mapping node and returns its value when it is a
+// scalar other than null or binary. The other values are left to the yaml decoder.
+func takeScalar(n *yaml.Node, path []string) (string, bool) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n == nil || n.Kind != yaml.MappingNode || len(path) == 0 {
+		return "", false
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value != path[0] {
+			continue
+		}
+
+		v := n.Content[i+1]
+		if len(path) > 1 {
+			return takeScalar(v, path[1:])
+		}
+
+		if v.Kind == yaml.AliasNode {
+			v = v.Alias
+		}
+		if v == nil || v.Kind != yaml.ScalarNode || v.Tag == "!!null" || v.Tag == "!!binary" {
+			return "", false
+		}
+
+		n.Content = append(n.Content[:i:i], n.Content[i+2:]...)
+		return v.Value, true
+	}
+
+	return "", false
+}